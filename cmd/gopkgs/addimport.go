@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var addImportUsageInfo = `
+Adds the imports to the Go file, on the standard library, third party or local group of
+the import declaration. The argument is an import path, or a package name resolved to
+the packages of that name importable from the file: standard library first, then the
+packages of the module, the required modules and the other ones. On ambiguity the first
+one is imported, unless -i prompts for the package to import.
+`
+
+func runAddImport(args []string) error {
+	fs := newFlagSet("add-import")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["add-import"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, addImportUsageInfo)
+	}
+	lf := addListFlags(fs)
+	file := fs.String("file", "", "Go file to add the imports to")
+	interactive := fs.Bool("i", false, "prompt for the package to import when the name is ambiguous")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *file == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitCode(2)
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(*file))
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		opts.WorkDir = dir
+	}
+
+	ix := newIndex(opts)
+	if err := ix.ensure(); err != nil {
+		return err
+	}
+
+	modPath, pkgPath := fileModulePath(dir)
+	in := bufio.NewReader(os.Stdin)
+	for _, arg := range fs.Args() {
+		cands, err := resolvePkg(ix, arg, modPath, pkgPath)
+		if err != nil {
+			return err
+		}
+
+		pkg := cands[0]
+		if len(cands) > 1 {
+			if *interactive {
+				var ok bool
+				if pkg, ok = choosePkg(cands, in, os.Stderr); !ok {
+					return exitCode(1)
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %d packages named %s, importing %s\n", *file, len(cands), arg, pkg.ImportPath)
+			}
+		}
+
+		added, err := addImportFile(*file, importName(pkg.ImportPath, pkg.Name), pkg.ImportPath)
+		if err != nil {
+			return err
+		}
+
+		if !added {
+			fmt.Fprintf(os.Stderr, "%s already imports %s\n", *file, pkg.ImportPath)
+		}
+	}
+	return nil
+}
+
+// resolvePkg returns the package of the import path, or else the packages named arg
+// importable from the package pkgPath of the module modPath, best first.
+func resolvePkg(ix *index, arg, modPath, pkgPath string) ([]gopkgs.Pkg, error) {
+	if pkg, found := ix.get(arg); found {
+		return []gopkgs.Pkg{pkg}, nil
+	}
+
+	if strings.Contains(arg, "/") {
+		return nil, fmt.Errorf("package %q not found", arg)
+	}
+
+	pkgs := pkgsNamed(ix, arg, modPath, pkgPath)
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no package named %q", arg)
+	}
+	return pkgs, nil
+}
+
+// pkgsNamed returns the packages of the name importable from the package pkgPath of the
+// module modPath, best first.
+func pkgsNamed(ix *index, name, modPath, pkgPath string) []gopkgs.Pkg {
+	var pkgs []gopkgs.Pkg
+	for _, pkg := range ix.list("") {
+		if pkg.Name == name && pkg.ImportPath != pkgPath && importable(pkg.ImportPath, pkgPath) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return importRank(pkgs[i], modPath) < importRank(pkgs[j], modPath)
+	})
+	return pkgs
+}
+
+// importRank orders the packages of the same name: standard library, then the packages of
+// the module modPath, of the required modules and of the other modules, then by pkgRank.
+func importRank(pkg gopkgs.Pkg, modPath string) string {
+	var group string
+	switch {
+	case pkg.Standard:
+		group = "0"
+	case importGroup(pkg.ImportPath, modPath) == localGroup:
+		group = "1"
+	case !pkg.NotRequired:
+		group = "2"
+	default:
+		group = "3"
+	}
+	return group + pkgRank(pkg)
+}
+
+// choosePkg prompts for one of the packages by number, false if the input ends.
+func choosePkg(pkgs []gopkgs.Pkg, in *bufio.Reader, out io.Writer) (gopkgs.Pkg, bool) {
+	for i, pkg := range pkgs {
+		fmt.Fprintf(out, "%2d) %s\n", i+1, pkg.ImportPath)
+	}
+
+	for {
+		fmt.Fprint(out, "number: ")
+		s, err := in.ReadString('\n')
+		s = strings.TrimSpace(s)
+		if n, aerr := strconv.Atoi(s); aerr == nil && n >= 1 && n <= len(pkgs) {
+			return pkgs[n-1], true
+		}
+
+		if err != nil {
+			return gopkgs.Pkg{}, false
+		}
+		fmt.Fprintln(out, "invalid number", s)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// exitViolation is the exit code of check when cycles or forbidden imports are found.
+const exitViolation = 2
+
+var checkUsageInfo = `
+The rules file lists one rule per line, blank lines and lines starting with # are ignored:
+	deny  <from> <to>   packages matching <from> must not import packages matching <to>
+	allow <from> <to>   exception to the deny rules
+
+Patterns may contain "..." wildcards. Patterns "." or starting with "./" are relative to the
+import path of workDir, e.g.
+	deny  ./internal/domain/... ./internal/http/...
+	allow ./internal/domain/... ./internal/http/errors
+`
+
+// rule is a layering rule, denying or allowing the imports between the matching packages.
+type rule struct {
+	allow bool
+	from  func(string) bool
+	to    func(string) bool
+	pos   string // file:line of the rule
+	text  string
+}
+
+func (r rule) match(from, to string) bool {
+	return r.from(from) && r.to(to)
+}
+
+func runCheck(args []string) error {
+	fs := newFlagSet("check")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["check"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, checkUsageInfo)
+	}
+	lf := addListFlags(fs)
+	rulesFile := fs.String("rules", "", "layering rules file")
+	noCycles := fs.Bool("no-cycles", false, "do not report import cycles")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		opts.WorkDir = "."
+	}
+
+	workDir, err := filepath.Abs(opts.WorkDir)
+	if err != nil {
+		return err
+	}
+
+	opts.Imports = true
+	opts.IncludeMain = true
+	pkgs, err := gopkgs.List(opts)
+	if err != nil {
+		return err
+	}
+
+	local := localPkgs(workDir, pkgs)
+	var rules []rule
+	if *rulesFile != "" {
+		rules, err = readRules(*rulesFile, workDirImportPath(workDir, local))
+		if err != nil {
+			return err
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	var violations int
+	if !*noCycles {
+		for _, cycle := range gopkgs.NewGraph(local).Cycles() {
+			fmt.Fprintln(w, "import cycle:", strings.Join(cycle, " -> "))
+			violations++
+		}
+	}
+
+	for _, pkg := range sortedPkgs(local) {
+		for _, imp := range pkg.Imports {
+			if r, denied := checkImport(rules, pkg.ImportPath, imp); denied {
+				fmt.Fprintf(w, "forbidden import: %s imports %s (%s: %s)\n", pkg.ImportPath, imp, r.pos, r.text)
+				violations++
+			}
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if violations > 0 {
+		fmt.Fprintf(os.Stderr, "%d violation(s) found\n", violations)
+		return exitCode(exitViolation)
+	}
+	return nil
+}
+
+// checkImport returns the rule denying from to import to, if any.
+func checkImport(rules []rule, from, to string) (rule, bool) {
+	for _, r := range rules {
+		if r.allow && r.match(from, to) {
+			return rule{}, false
+		}
+	}
+
+	for _, r := range rules {
+		if !r.allow && r.match(from, to) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+func readRules(filename, base string) ([]rule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []rule
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pos := fmt.Sprintf("%s:%d", filename, n)
+		fields := strings.Fields(line)
+		if len(fields) != 3 || (fields[0] != "deny" && fields[0] != "allow") {
+			return nil, fmt.Errorf("%s: expect pattern 'deny|allow <from> <to>': %s", pos, line)
+		}
+
+		from, err := resolvePattern(fields[1], base)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pos, err)
+		}
+
+		to, err := resolvePattern(fields[2], base)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pos, err)
+		}
+
+		rules = append(rules, rule{
+			allow: fields[0] == "allow",
+			from:  gopkgs.MatchPattern(from),
+			to:    gopkgs.MatchPattern(to),
+			pos:   pos,
+			text:  line,
+		})
+	}
+
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func resolvePattern(pattern, base string) (string, error) {
+	if pattern != "." && !strings.HasPrefix(pattern, "./") {
+		return pattern, nil
+	}
+
+	if base == "" {
+		return "", fmt.Errorf("cannot resolve relative pattern %s, import path of workDir is unknown", pattern)
+	}
+	return base + pattern[len("."):], nil
+}
+
+// localPkgs returns the packages under workDir, the vendored ones excluded.
+func localPkgs(workDir string, pkgs map[string]gopkgs.Pkg) map[string]gopkgs.Pkg {
+	local := make(map[string]gopkgs.Pkg)
+	for dir, pkg := range pkgs {
+		rel, ok := relDir(workDir, dir)
+		if !ok {
+			continue
+		}
+
+		vendored := false
+		for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+			if elem == "vendor" {
+				vendored = true
+				break
+			}
+		}
+
+		if !vendored {
+			local[dir] = pkg
+		}
+	}
+	return local
+}
+
+// workDirImportPath returns the import path of workDir, from the module path of the enclosing
+// go.mod, else from the GOPATH src directory of the packages under it.
+func workDirImportPath(workDir string, pkgs map[string]gopkgs.Pkg) string {
+	if _, pkgPath := fileModulePath(workDir); pkgPath != "" {
+		return pkgPath
+	}
+
+	for _, pkg := range sortedPkgs(pkgs) {
+		if pkg.Standard || pkg.Module != "" {
+			continue
+		}
+
+		if rel, ok := relDir(pkg.Root, workDir); ok && rel != "." {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
+// relDir returns the path of dir relative to base, if dir is base or under it.
+func relDir(base, dir string) (string, bool) {
+	rel, err := filepath.Rel(base, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+func sortedPkgs(pkgs map[string]gopkgs.Pkg) []gopkgs.Pkg {
+	list := make([]gopkgs.Pkg, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		list = append(list, pkg)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ImportPath < list[j].ImportPath
+	})
+	return list
+}
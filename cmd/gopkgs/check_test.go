@@ -0,0 +1,249 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestResolvePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{pattern: "net/...", base: "example.com/m", want: "net/..."},
+		{pattern: ".", base: "example.com/m", want: "example.com/m"},
+		{pattern: "./internal/...", base: "example.com/m", want: "example.com/m/internal/..."},
+		{pattern: "./cmd/app", base: "example.com/m", want: "example.com/m/cmd/app"},
+		{pattern: "./internal/...", base: "", wantErr: true},
+		{pattern: "example.com/x", base: "", want: "example.com/x"},
+	}
+
+	for _, c := range cases {
+		got, err := resolvePattern(c.pattern, c.base)
+		if (err != nil) != c.wantErr {
+			t.Errorf("resolvePattern(%q, %q) error: %v, want error: %t", c.pattern, c.base, err, c.wantErr)
+			continue
+		}
+
+		if got != c.want {
+			t.Errorf("resolvePattern(%q, %q) got: %q, want: %q", c.pattern, c.base, got, c.want)
+		}
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.txt")
+	content := `# layering
+deny  ./internal/domain/... ./internal/http/...
+
+allow ./internal/domain/... ./internal/http/errors
+deny  ./cmd/... database/sql
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := readRules(filename, "example.com/m")
+	if err != nil {
+		t.Fatal("fail reading rules:", err)
+	}
+
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want: 3", len(rules))
+	}
+
+	if rules[0].allow || !rules[1].allow || rules[2].allow {
+		t.Errorf("got allow: %t %t %t, want: false true false", rules[0].allow, rules[1].allow, rules[2].allow)
+	}
+
+	if want := filename + ":4"; rules[1].pos != want {
+		t.Errorf("got pos: %q, want: %q", rules[1].pos, want)
+	}
+
+	if !rules[2].match("example.com/m/cmd/app", "database/sql") || rules[2].match("example.com/m/internal/x", "database/sql") {
+		t.Error("rule ./cmd/... database/sql does not match as expected")
+	}
+}
+
+func TestReadRulesInvalid(t *testing.T) {
+	cases := []struct {
+		content string
+		base    string
+		wantErr string
+	}{
+		{content: "deny a", base: "example.com/m", wantErr: ":1: expect pattern"},
+		{content: "\nforbid a b", base: "example.com/m", wantErr: ":2: expect pattern"},
+		{content: "deny ./a b", base: "", wantErr: ":1: cannot resolve relative pattern ./a"},
+	}
+
+	for _, c := range cases {
+		filename := filepath.Join(t.TempDir(), "rules.txt")
+		if err := ioutil.WriteFile(filename, []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := readRules(filename, c.base)
+		if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("readRules(%q) error: %v, want containing: %q", c.content, err, c.wantErr)
+		}
+	}
+}
+
+func TestCheckImport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.txt")
+	content := `deny  ./internal/domain/... ./internal/http/...
+allow ./internal/domain/... ./internal/http/errors
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := readRules(filename, "example.com/m")
+	if err != nil {
+		t.Fatal("fail reading rules:", err)
+	}
+
+	cases := []struct {
+		from, to string
+		denied   bool
+	}{
+		{from: "example.com/m/internal/domain", to: "example.com/m/internal/http", denied: true},
+		{from: "example.com/m/internal/domain/user", to: "example.com/m/internal/http/router", denied: true},
+		{from: "example.com/m/internal/domain", to: "example.com/m/internal/http/errors", denied: false},
+		{from: "example.com/m/internal/http", to: "example.com/m/internal/domain", denied: false},
+		{from: "example.com/m/internal/domain", to: "fmt", denied: false},
+	}
+
+	for _, c := range cases {
+		r, denied := checkImport(rules, c.from, c.to)
+		if denied != c.denied {
+			t.Errorf("checkImport(%s, %s) got denied: %t, want: %t", c.from, c.to, denied, c.denied)
+		}
+
+		if denied && r.pos != filename+":1" {
+			t.Errorf("checkImport(%s, %s) got rule at %s, want: %s:1", c.from, c.to, r.pos, filename)
+		}
+	}
+}
+
+func TestLocalPkgs(t *testing.T) {
+	workDir := filepath.FromSlash("/src/m")
+	pkgs := map[string]gopkgs.Pkg{}
+	for _, dir := range []string{"/src/m", "/src/m/a", "/src/m/vendor/example.com/v", "/src/m/a/vendor/x", "/src/m/vendored", "/src/mod", "/src/other"} {
+		dir = filepath.FromSlash(dir)
+		pkgs[dir] = gopkgs.Pkg{Dir: dir}
+	}
+
+	var got []string
+	for dir := range localPkgs(workDir, pkgs) {
+		got = append(got, filepath.ToSlash(dir))
+	}
+	sort.Strings(got)
+
+	if want := []string{"/src/m", "/src/m/a", "/src/m/vendored"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestWorkDirImportPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"m/go.mod":       "module example.com/m\n\ngo 1.16\n",
+		"m/app/app.go":   "package app\n",
+		"src/x/y/y.go":   "package y\n",
+		"src/x/y/z/z.go": "package z\n",
+	})
+
+	gopathPkgs := map[string]gopkgs.Pkg{
+		filepath.Join(dir, "src", "x", "y"):      {ImportPath: "x/y", Root: filepath.Join(dir, "src")},
+		filepath.Join(dir, "src", "x", "y", "z"): {ImportPath: "x/y/z", Root: filepath.Join(dir, "src")},
+	}
+
+	cases := []struct {
+		name    string
+		workDir string
+		pkgs    map[string]gopkgs.Pkg
+		want    string
+	}{
+		{name: "module root", workDir: filepath.Join(dir, "m"), want: "example.com/m"},
+		{name: "module subdirectory", workDir: filepath.Join(dir, "m", "app"), want: "example.com/m/app"},
+		{name: "gopath", workDir: filepath.Join(dir, "src", "x", "y"), pkgs: gopathPkgs, want: "x/y"},
+		{name: "gopath src", workDir: filepath.Join(dir, "src"), pkgs: gopathPkgs, want: ""},
+		{name: "unknown", workDir: filepath.Join(dir, "src", "x"), want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := workDirImportPath(c.workDir, c.pkgs); got != c.want {
+				t.Errorf("got: %q, want: %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{
+		"cmd/app/main.go":        "package main\n\nimport _ \"example.com/m/internal/http\"\n\nfunc main() {}\n",
+		"internal/http/http.go":  "package http\n",
+		"internal/store/a/a.go":  "package a\n\nimport _ \"example.com/m/internal/store/b\"\n",
+		"internal/store/b/b.go":  "package b\n\nimport _ \"example.com/m/internal/store/a\"\n",
+		"rules.txt":              "deny ./cmd/... ./internal/http/...\n",
+		"allowed.txt":            "deny ./internal/http/... ./cmd/...\n",
+		"internal/domain/dom.go": "package domain\n",
+	})
+
+	cases := []struct {
+		name     string
+		args     []string
+		wantOut  []string
+		wantCode error
+	}{
+		{
+			name:     "main package import",
+			args:     []string{"-workDir", dir, "-no-cycles", "-rules", filepath.Join(dir, "rules.txt")},
+			wantOut:  []string{"forbidden import: example.com/m/cmd/app imports example.com/m/internal/http"},
+			wantCode: exitCode(exitViolation),
+		},
+		{
+			name:     "cycle",
+			args:     []string{"-workDir", dir},
+			wantOut:  []string{"import cycle: example.com/m/internal/store/a -> example.com/m/internal/store/b -> example.com/m/internal/store/a"},
+			wantCode: exitCode(exitViolation),
+		},
+		{
+			name: "no violation",
+			args: []string{"-workDir", dir, "-no-cycles", "-rules", filepath.Join(dir, "allowed.txt")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := captureStdout(t, func() error { return runCheck(c.args) })
+			if err != c.wantCode {
+				t.Fatalf("got error: %v, want: %v", err, c.wantCode)
+			}
+
+			for _, want := range c.wantOut {
+				if !strings.Contains(out, want) {
+					t.Errorf("got output:\n%s\nwant containing: %s", out, want)
+				}
+			}
+
+			if len(c.wantOut) == 0 && out != "" {
+				t.Errorf("got output:\n%s\nwant none", out)
+			}
+		})
+	}
+}
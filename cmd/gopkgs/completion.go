@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var completionUsageInfo = `
+Prints the completion script of the shell, completing the package arguments of the go commands
+(go get, go doc, go test, ...) and of gopkgs with the import paths listed by gopkgs. E.g.:
+	bash  source <(gopkgs completion bash)
+	zsh   source <(gopkgs completion zsh)
+	fish  gopkgs completion fish | source
+`
+
+// goPkgCommands are the go commands taking packages as arguments.
+const goPkgCommands = "build doc get install list run test vet"
+
+// pkgArgCommands are the gopkgs commands taking an import path as argument.
+const pkgArgCommands = "add-import deps importers"
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion of the go and gopkgs package arguments, generated by gopkgs completion bash
+_gopkgs_complete_pkgs() {
+	local IFS=$'\n'
+	COMPREPLY=($(gopkgs __complete -- "$1" 2>/dev/null))
+}
+
+# the go completion already defined, loaded first if lazily loaded by bash-completion
+if ! complete -p go &>/dev/null && declare -F _completion_loader &>/dev/null; then
+	_completion_loader go
+fi
+if [[ $(complete -p go 2>/dev/null) =~ -F\ ([^ ]+) && ${BASH_REMATCH[1]} != _gopkgs_go ]]; then
+	_gopkgs_go_next=${BASH_REMATCH[1]}
+fi
+
+_gopkgs_go() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [[ $COMP_CWORD -ge 2 && $cur != -* && " {{.GoCommands}} " == *" ${COMP_WORDS[1]} "* ]]; then
+		_gopkgs_complete_pkgs "$cur"
+	elif [[ -n $_gopkgs_go_next ]]; then
+		"$_gopkgs_go_next" "$@"
+	fi
+}
+
+_gopkgs() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [[ $COMP_CWORD -eq 1 ]]; then
+		COMPREPLY=($(compgen -W "{{.Commands}}" -- "$cur"))
+	elif [[ $cur != -* && ${COMP_WORDS[COMP_CWORD-1]} != -file && " {{.PkgCommands}} " == *" ${COMP_WORDS[1]} "* ]]; then
+		_gopkgs_complete_pkgs "$cur"
+	fi
+}
+
+complete -o default -F _gopkgs_go go
+complete -o default -F _gopkgs gopkgs
+`,
+	"zsh": `# zsh completion of the go and gopkgs package arguments, generated by gopkgs completion zsh
+_gopkgs_complete_pkgs() {
+	local -a pkgs
+	pkgs=(${(f)"$(gopkgs __complete -- "${words[CURRENT]}" 2>/dev/null)"})
+	compadd -- $pkgs
+}
+
+_gopkgs_go() {
+	if (( CURRENT > 2 )) && [[ ${words[CURRENT]} != -* && " {{.GoCommands}} " == *" ${words[2]} "* ]]; then
+		_gopkgs_complete_pkgs
+		_files
+	elif (( $+functions[_go] )); then
+		_go "$@"
+	else
+		_files
+	fi
+}
+
+_gopkgs() {
+	if (( CURRENT == 2 )); then
+		compadd -- {{.Commands}}
+	elif [[ ${words[CURRENT]} != -* && ${words[CURRENT-1]} != -file && " {{.PkgCommands}} " == *" ${words[2]} "* ]]; then
+		_gopkgs_complete_pkgs
+	else
+		_files
+	fi
+}
+
+compdef _gopkgs_go go
+compdef _gopkgs gopkgs
+`,
+	"fish": `# fish completion of the go and gopkgs package arguments, generated by gopkgs completion fish
+complete -c go -n '__fish_seen_subcommand_from {{.GoCommands}}' -a '(gopkgs __complete -- (commandline -ct) 2>/dev/null)'
+complete -c gopkgs -n '__fish_use_subcommand' -f -a '{{.Commands}}'
+complete -c gopkgs -n '__fish_seen_subcommand_from {{.PkgCommands}}; and not __fish_prev_arg_in -file' -f -a '(gopkgs __complete -- (commandline -ct) 2>/dev/null)'
+`,
+}
+
+func runCompletion(args []string) error {
+	fs := newFlagSet("completion")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["completion"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, completionUsageInfo)
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(2)
+	}
+
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q, expect bash, zsh or fish", fs.Arg(0))
+	}
+
+	r := strings.NewReplacer(
+		"{{.GoCommands}}", goPkgCommands,
+		"{{.PkgCommands}}", pkgArgCommands,
+		"{{.Commands}}", strings.Join(commandNames(), " "),
+	)
+	_, err := fmt.Print(r.Replace(script))
+	return err
+}
+
+// runComplete prints the import paths starting with the prefix, for the completion scripts.
+func runComplete(args []string) error {
+	fs := newFlagSet("__complete")
+	lf := addListFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return errors.New("expect at most one prefix")
+	}
+	prefix := fs.Arg(0)
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		if opts.WorkDir, err = filepath.Abs("."); err != nil {
+			return err
+		}
+	}
+	// e.g. go run and go install take the main packages
+	opts.IncludeMain = true
+
+	pkgs, err := gopkgs.List(opts)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, pkg := range pkgs {
+		if strings.HasPrefix(pkg.ImportPath, prefix) && !seen[pkg.ImportPath] {
+			seen[pkg.ImportPath] = true
+			matches = append(matches, pkg.ImportPath)
+		}
+	}
+	sort.Strings(matches)
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, m := range matches {
+		fmt.Fprintln(w, m)
+	}
+	return w.Flush()
+}
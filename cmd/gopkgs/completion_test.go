@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRunComplete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{
+		"cmd/app/main.go": "package main\n\nfunc main() {}\n",
+		"lib/lib.go":      "package lib\n",
+		"lib/sub/sub.go":  "package sub\n",
+	})
+
+	cases := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "example.com/m/", want: "example.com/m/cmd/app\nexample.com/m/lib\nexample.com/m/lib/sub\n"},
+		{prefix: "example.com/m/lib/", want: "example.com/m/lib/sub\n"},
+		{prefix: "example.com/x", want: ""},
+	}
+
+	for _, c := range cases {
+		out, err := captureStdout(t, func() error { return runComplete([]string{"-workDir", dir, c.prefix}) })
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out != c.want {
+			t.Errorf("complete %q got:\n%s\nwant:\n%s", c.prefix, out, c.want)
+		}
+	}
+}
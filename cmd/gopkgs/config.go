@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var configUsageInfo = `
+The flags not given on the command line are read, in order of precedence, from:
+	the environment     GOPKGS_<COMMAND>_<FLAG>, e.g. GOPKGS_GRAPH_TYPE, then for gopkgs and the
+	                    listing flags GOPKGS_<FLAG>, e.g. GOPKGS_WORKDIR or GOPKGS_NO_VENDOR
+	the project config  .gopkgs.toml or .gopkgs.json on the current directory or its parents
+	the user config     gopkgs/config.toml or gopkgs/config.json under $XDG_CONFIG_HOME (~/.config)
+
+An empty variable is set, e.g. GOPKGS_EXCLUDE= clears the exclude of the config files. The -w
+flag, writing the files, is only read from the command line.
+
+The config files set the flags by name, the top-level ones applying to gopkgs and to the listing
+flags of the commands (e.g. workDir), the ones under a command section only to that command. The relative workDir,
+overlay and rules are resolved against the directory of the config file. E.g.:
+	workDir = "."
+	no-vendor = true
+	exclude = ["bazel-out", "/third_party/js"]
+
+	[graph]
+	type = "mermaid"
+
+Prints the effective flags of gopkgs, along with where each one is set.
+`
+
+// Config files.
+const (
+	projectConfigName = ".gopkgs"
+	userConfigName    = "config"
+)
+
+// pathFlags are the flags holding a path, resolved against the directory of the config file.
+var pathFlags = map[string]bool{"workDir": true, "overlay": true, "rules": true}
+
+// cmdLineFlags are the flags only read from the command line, never from the environment nor
+// the config files.
+var cmdLineFlags = map[string]bool{"w": true}
+
+// configFile is a config file, the values keyed by command section then flag name.
+// The top-level values are on the empty section.
+type configFile struct {
+	path   string
+	values map[string]map[string]string
+}
+
+// lookup returns the value of the flag for the command, the command section taking precedence.
+func (c *configFile) lookup(cmd, name string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	sections := []string{cmd}
+	if cmd == "" || isListFlag(name) {
+		sections = append(sections, "")
+	}
+
+	for _, section := range sections {
+		if v, ok := c.values[section][name]; ok {
+			if pathFlags[name] && v != "" && !filepath.IsAbs(v) {
+				v = filepath.Join(filepath.Dir(c.path), v)
+			}
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// isListFlag reports whether the flag controls how the packages are listed, see addListFlags.
+func isListFlag(name string) bool {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	addListFlags(fs)
+	return fs.Lookup(name) != nil
+}
+
+// configs are the config files in order of precedence.
+type configs struct {
+	project *configFile
+	user    *configFile
+}
+
+// loadConfigs reads the project and the user config files, if any.
+func loadConfigs() (*configs, error) {
+	var cfgs configs
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		if cfgs.project, err = readConfigFile(dir, projectConfigName); err != nil || cfgs.project != nil {
+			break
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := userConfigDir(); dir != "" {
+		if cfgs.user, err = readConfigFile(filepath.Join(dir, "gopkgs"), userConfigName); err != nil {
+			return nil, err
+		}
+	}
+	return &cfgs, nil
+}
+
+// userConfigDir returns $XDG_CONFIG_HOME, or ~/.config.
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
+
+// readConfigFile reads the name.toml or the name.json config file on dir, nil if there is none.
+func readConfigFile(dir, name string) (*configFile, error) {
+	for _, ext := range []string{".toml", ".json"} {
+		path := filepath.Join(dir, name+ext)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var values map[string]map[string]string
+		if ext == ".toml" {
+			values, err = parseTOML(data)
+		} else {
+			values, err = parseJSONConfig(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return &configFile{path: path, values: values}, nil
+	}
+	return nil, nil
+}
+
+// parseTOML parses the subset of TOML used by the config: the key/value pairs of strings,
+// booleans, numbers and arrays of them, grouped by [section]. The arrays are joined by commas.
+func parseTOML(data []byte) (map[string]map[string]string, error) {
+	values := map[string]map[string]string{"": {}}
+	section := ""
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", n, line)
+			}
+
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if values[section] == nil {
+				values[section] = make(map[string]string)
+			}
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expect key = value", n)
+		}
+
+		key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		value, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		values[section][key] = value
+	}
+	return values, s.Err()
+}
+
+func parseTOMLValue(s string) (string, error) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.LastIndexByte(s, ']')
+		if end < 0 {
+			return "", errors.New("unterminated array, arrays must be on a single line")
+		}
+
+		var elems []string
+		rest := strings.TrimSpace(s[1:end])
+		for rest != "" {
+			elem, n, err := tomlScalar(rest)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, elem)
+
+			rest = strings.TrimSpace(rest[n:])
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+		}
+		return strings.Join(elems, ","), nil
+	}
+
+	v, n, err := tomlScalar(s)
+	if err != nil {
+		return "", err
+	}
+
+	if rest := strings.TrimSpace(s[n:]); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after the value", rest)
+	}
+	return v, nil
+}
+
+// tomlScalar parses the string, boolean or number at the start of s, returning its value and length.
+func tomlScalar(s string) (string, int, error) {
+	if s == "" || s[0] == '#' {
+		return "", 0, errors.New("expect a value")
+	}
+
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+
+			if s[i] == '"' {
+				v, err := strconv.Unquote(s[:i+1])
+				return v, i + 1, err
+			}
+		}
+		return "", 0, errors.New("unterminated string")
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", 0, errors.New("unterminated string")
+		}
+		return s[1 : end+1], end + 2, nil
+	}
+
+	n := strings.IndexAny(s, " \t,#]")
+	if n < 0 {
+		n = len(s)
+	}
+
+	v := s[:n]
+	if v != "true" && v != "false" {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "", 0, fmt.Errorf("invalid value %q, strings must be quoted", v)
+		}
+	}
+	return v, n, nil
+}
+
+// parseJSONConfig parses the JSON object of the flag values, the nested objects being the command sections.
+func parseJSONConfig(data []byte) (map[string]map[string]string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	values := map[string]map[string]string{"": {}}
+	for key, v := range obj {
+		section, ok := v.(map[string]interface{})
+		if !ok {
+			s, err := jsonConfigValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			values[""][key] = s
+			continue
+		}
+
+		values[key] = make(map[string]string)
+		for name, v := range section {
+			s, err := jsonConfigValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", key, name, err)
+			}
+			values[key][name] = s
+		}
+	}
+	return values, nil
+}
+
+func jsonConfigValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, elem := range v {
+			s, err := jsonConfigValue(elem)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return strings.Join(elems, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// envNames returns the environment variables of the flag for the command, in order of
+// precedence, e.g. GOPKGS_GRAPH_TYPE for the type of graph. Like the config sections, the
+// variables without command apply to gopkgs and to the listing flags of the commands,
+// e.g. GOPKGS_NO_VENDOR for no-vendor.
+func envNames(cmd, flagName string) []string {
+	upper := func(s string) string {
+		return strings.ToUpper(strings.Replace(s, "-", "_", -1))
+	}
+
+	var names []string
+	if cmd != "" {
+		names = append(names, "GOPKGS_"+upper(cmd)+"_"+upper(flagName))
+	}
+	if cmd == "" || isListFlag(flagName) {
+		names = append(names, "GOPKGS_"+upper(flagName))
+	}
+	return names
+}
+
+// lookupEnv returns the value of the first environment variable set of the flag, and its name.
+func lookupEnv(cmd, flagName string) (string, string, bool) {
+	for _, name := range envNames(cmd, flagName) {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, name, true
+		}
+	}
+	return "", "", false
+}
+
+// applyConfig sets the flags not given on the command line from the environment and the
+// config files, returning where each flag is set. The command is empty for gopkgs itself.
+func applyConfig(fs *flag.FlagSet, cmd string, cfgs *configs) (map[string]string, error) {
+	sources := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		sources[f.Name] = "flag"
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || sources[f.Name] != "" || cmdLineFlags[f.Name] {
+			return
+		}
+
+		value, source, ok := lookupEnv(cmd, f.Name)
+		if !ok {
+			if value, ok = cfgs.project.lookup(cmd, f.Name); ok {
+				source = cfgs.project.path
+			} else if value, ok = cfgs.user.lookup(cmd, f.Name); ok {
+				source = cfgs.user.path
+			} else {
+				return
+			}
+		}
+
+		if serr := fs.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("invalid value %q for flag -%s from %s: %v", value, f.Name, source, serr)
+			return
+		}
+		sources[f.Name] = source
+	})
+	return sources, err
+}
+
+// parseFlags parses the flags of the command, then applies the config.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfgs, err := loadConfigs()
+	if err != nil {
+		return err
+	}
+
+	_, err = applyConfig(fs, fs.Name(), cfgs)
+	return err
+}
+
+func runConfig(args []string) error {
+	fs := flag.CommandLine
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["config"].usage)
+		fmt.Fprint(os.Stderr, configUsageInfo)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfgs, err := loadConfigs()
+	if err != nil {
+		return err
+	}
+
+	sources, err := applyConfig(fs, "", cfgs)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, c := range []*configFile{cfgs.project, cfgs.user} {
+		if c != nil {
+			fmt.Fprintf(w, "# config: %s\n", c.path)
+		}
+	}
+
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "help" {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		source := sources[name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%s = %s # %s\n", name, configValue(fs.Lookup(name).Value.String()), source)
+	}
+
+	// the command sections, applied by the commands themselves
+	for _, c := range []*configFile{cfgs.project, cfgs.user} {
+		if c == nil {
+			continue
+		}
+
+		var sections []string
+		for section := range c.values {
+			if section != "" && len(c.values[section]) > 0 {
+				sections = append(sections, section)
+			}
+		}
+		sort.Strings(sections)
+
+		for _, section := range sections {
+			fmt.Fprintf(w, "\n[%s] # %s\n", section, c.path)
+
+			keys := make([]string, 0, len(c.values[section]))
+			for key := range c.values[section] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				fmt.Fprintf(w, "%s = %s\n", key, configValue(c.values[section][key]))
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// configValue formats the flag value as TOML.
+func configValue(v string) string {
+	if v == "true" || v == "false" {
+		return v
+	}
+
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	return strconv.Quote(v)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		{
+			name: "values",
+			data: `# comment
+workDir = "."
+no-vendor = true
+concurrency = 4 # trailing comment
+exclude = ["bazel-out", '/third_party/js']
+"format" = "{{.ImportPath}}\t{{.Dir}}"
+`,
+			want: map[string]map[string]string{"": {
+				"workDir":     ".",
+				"no-vendor":   "true",
+				"concurrency": "4",
+				"exclude":     "bazel-out,/third_party/js",
+				"format":      "{{.ImportPath}}\t{{.Dir}}",
+			}},
+		},
+		{
+			name: "sections",
+			data: "workDir = \"/src\"\n\n[graph]\ntype = \"mermaid\"\n\n[ check ]\nno-cycles = true\n",
+			want: map[string]map[string]string{
+				"":      {"workDir": "/src"},
+				"graph": {"type": "mermaid"},
+				"check": {"no-cycles": "true"},
+			},
+		},
+		{name: "unquoted string", data: "workDir = src\n", wantErr: true},
+		{name: "missing value", data: "workDir\n", wantErr: true},
+		{name: "empty value", data: "workDir =\n", wantErr: true},
+		{name: "comment value", data: "workDir = # none\n", wantErr: true},
+		{name: "invalid section", data: "[graph\n", wantErr: true},
+		{name: "unterminated string", data: "workDir = \"src\n", wantErr: true},
+		{name: "multiline array", data: "exclude = [\n\"a\",\n]\n", wantErr: true},
+		{name: "trailing value", data: "workDir = \"a\" \"b\"\n", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseTOML([]byte(c.data))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error: %v, want error: %t", err, c.wantErr)
+			}
+
+			if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %v, want: %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		{
+			name: "values",
+			data: `{"workDir": ".", "no-vendor": true, "concurrency": 4, "exclude": ["bazel-out", "/third_party/js"], "graph": {"type": "mermaid"}}`,
+			want: map[string]map[string]string{
+				"":      {"workDir": ".", "no-vendor": "true", "concurrency": "4", "exclude": "bazel-out,/third_party/js"},
+				"graph": {"type": "mermaid"},
+			},
+		},
+		{name: "null value", data: `{"workDir": null}`, wantErr: true},
+		{name: "nested section", data: `{"graph": {"type": {"name": "dot"}}}`, wantErr: true},
+		{name: "invalid json", data: `{"workDir": }`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseJSONConfig([]byte(c.data))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error: %v, want error: %t", err, c.wantErr)
+			}
+
+			if !c.wantErr && !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %v, want: %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	project := &configFile{
+		path: "/project/.gopkgs.toml",
+		values: map[string]map[string]string{
+			"":      {"workDir": "src", "exclude": "gen", "type": "dot", "w": "true"},
+			"graph": {"no-vendor": "true"},
+		},
+	}
+	user := &configFile{
+		path: "/home/me/.config/gopkgs/config.toml",
+		values: map[string]map[string]string{
+			"":      {"exclude": "testdata", "concurrency": "2"},
+			"graph": {"type": "mermaid"},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		cfgs        *configs
+		want        map[string]string
+		wantSources map[string]string
+		wantErr     bool
+	}{
+		{
+			name: "config files",
+			cfgs: &configs{project: project, user: user},
+			want: map[string]string{"workDir": "/project/src", "exclude": "gen", "no-vendor": "true", "concurrency": "2", "type": "mermaid", "w": "false"},
+			wantSources: map[string]string{
+				"workDir":     project.path,
+				"exclude":     project.path,
+				"no-vendor":   project.path,
+				"concurrency": user.path,
+				"type":        user.path,
+			},
+		},
+		{
+			name:        "command line",
+			args:        []string{"-exclude", "vendor", "-type", "tree", "-w"},
+			env:         map[string]string{"GOPKGS_EXCLUDE": "env", "GOPKGS_GRAPH_TYPE": "env"},
+			cfgs:        &configs{project: project},
+			want:        map[string]string{"exclude": "vendor", "type": "tree", "w": "true"},
+			wantSources: map[string]string{"exclude": "flag", "type": "flag", "w": "flag", "workDir": project.path, "no-vendor": project.path},
+		},
+		{
+			name:        "list flag env",
+			env:         map[string]string{"GOPKGS_EXCLUDE": "env", "GOPKGS_NO_VENDOR": "false"},
+			cfgs:        &configs{project: project},
+			want:        map[string]string{"exclude": "env", "no-vendor": "false"},
+			wantSources: map[string]string{"exclude": "GOPKGS_EXCLUDE", "no-vendor": "GOPKGS_NO_VENDOR", "workDir": project.path},
+		},
+		{
+			name:        "command env",
+			env:         map[string]string{"GOPKGS_GRAPH_EXCLUDE": "graph", "GOPKGS_EXCLUDE": "env", "GOPKGS_GRAPH_TYPE": "tree", "GOPKGS_TYPE": "dot"},
+			cfgs:        &configs{},
+			want:        map[string]string{"exclude": "graph", "type": "tree"},
+			wantSources: map[string]string{"exclude": "GOPKGS_GRAPH_EXCLUDE", "type": "GOPKGS_GRAPH_TYPE"},
+		},
+		{
+			name:        "empty env",
+			env:         map[string]string{"GOPKGS_EXCLUDE": ""},
+			cfgs:        &configs{project: project},
+			want:        map[string]string{"exclude": ""},
+			wantSources: map[string]string{"exclude": "GOPKGS_EXCLUDE", "workDir": project.path, "no-vendor": project.path},
+		},
+		{
+			name:        "write flag",
+			env:         map[string]string{"GOPKGS_W": "true", "GOPKGS_GRAPH_W": "true"},
+			cfgs:        &configs{project: &configFile{path: project.path, values: map[string]map[string]string{"graph": {"w": "true"}}}},
+			want:        map[string]string{"w": "false"},
+			wantSources: map[string]string{},
+		},
+		{
+			name:    "invalid value",
+			env:     map[string]string{"GOPKGS_CONCURRENCY": "many"},
+			cfgs:    &configs{},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for key, value := range c.env {
+				defer setenv(t, key, value)()
+			}
+
+			fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			addListFlags(fs)
+			fs.String("type", "dot", "graph type")
+			fs.Bool("w", false, "write")
+			if err := fs.Parse(c.args); err != nil {
+				t.Fatal(err)
+			}
+
+			sources, err := applyConfig(fs, "graph", c.cfgs)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error: %v, want error: %t", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+
+			for name, want := range c.want {
+				if got := fs.Lookup(name).Value.String(); got != filepath.FromSlash(want) {
+					t.Errorf("got -%s: %q, want: %q", name, got, want)
+				}
+			}
+
+			if !reflect.DeepEqual(sources, c.wantSources) {
+				t.Errorf("got sources: %v, want: %v", sources, c.wantSources)
+			}
+		})
+	}
+}
+
+func TestApplyConfigGopkgs(t *testing.T) {
+	defer setenv(t, "GOPKGS_FORMAT", "{{.Name}}")()
+
+	cfgs := &configs{project: &configFile{
+		path:   "/project/.gopkgs.toml",
+		values: map[string]map[string]string{"": {"workDir": "/src"}, "graph": {"workDir": "/graph"}},
+	}}
+
+	fs := flag.NewFlagSet("gopkgs", flag.ContinueOnError)
+	workDir := fs.String("workDir", "", "")
+	format := fs.String("format", "", "")
+
+	if _, err := applyConfig(fs, "", cfgs); err != nil {
+		t.Fatal(err)
+	}
+
+	if *workDir != "/src" || *format != "{{.Name}}" {
+		t.Errorf("got workDir: %q, format: %q, want: %q, %q", *workDir, *format, "/src", "{{.Name}}")
+	}
+}
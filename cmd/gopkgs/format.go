@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var formatUsageInfo = `
+Along with the builtin template functions, -format provides:
+	json VALUE         JSON encoding of VALUE, e.g. {{json .}}
+	relpath PATH       PATH relative to workDir (or current directory), e.g. {{relpath .Dir}}
+	base PATH          last element of PATH, e.g. {{base .ImportPath}}
+	upper STRING       STRING in upper case
+	lower STRING       STRING in lower case
+	join SEP LIST      elements of LIST separated by SEP, e.g. {{join "," .GoFiles}}
+	pad WIDTH STRING   STRING padded with spaces on the right to WIDTH, like printf "%-*s"
+	lpad WIDTH STRING  STRING padded with spaces on the left to WIDTH, like printf "%*s"
+`
+
+// parseFormat parses the -format template, relpath being relative to workDir.
+func parseFormat(format, workDir string) (*template.Template, error) {
+	return template.New("out").Funcs(formatFuncs(workDir)).Parse(format)
+}
+
+func formatFuncs(workDir string) template.FuncMap {
+	return template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(v); err != nil {
+				return "", err
+			}
+			return strings.TrimSuffix(buf.String(), "\n"), nil
+		},
+		"relpath": func(path string) string {
+			base := workDir
+			if base == "" {
+				wd, err := os.Getwd()
+				if err != nil {
+					return path
+				}
+				base = wd
+			}
+
+			base, err := filepath.Abs(base)
+			if err != nil {
+				return path
+			}
+
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return path
+			}
+			return rel
+		},
+		"base":  filepath.Base,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"pad": func(width int, s string) string {
+			return fmt.Sprintf("%-*s", width, s)
+		},
+		"lpad": func(width int, s string) string {
+			return fmt.Sprintf("%*s", width, s)
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestParseFormat(t *testing.T) {
+	workDir := filepath.FromSlash("/work")
+	pkg := gopkgs.Pkg{
+		ImportPath: "example.com/m/a&b",
+		Name:       "ab",
+		Dir:        filepath.FromSlash("/work/m/<a&b>"),
+		GoFiles:    []string{"a.go", `"b".go`},
+	}
+
+	cases := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{name: "fields", format: "{{.ImportPath}} {{.Dir}}", want: "example.com/m/a&b " + filepath.FromSlash("/work/m/<a&b>")},
+		{name: "quotes", format: `{{join "," .GoFiles}} {{printf "%q" .Name}}`, want: `a.go,"b".go "ab"`},
+		{name: "json", format: `{{json .GoFiles}} {{json .ImportPath}} {{json "<a>"}}`, want: `["a.go","\"b\".go"] "example.com/m/a&b" "<a>"`},
+		{name: "relpath", format: "{{relpath .Dir}}", want: filepath.FromSlash("m/<a&b>")},
+		{name: "base", format: "{{base .ImportPath}} {{upper .Name}} {{lower \"AB\"}}", want: "a&b AB ab"},
+		{name: "pad", format: "[{{pad 5 .Name}}][{{pad 1 .Name}}]", want: "[ab   ][ab]"},
+		{name: "lpad", format: "[{{lpad 5 .Name}}][{{lpad 1 .Name}}]", want: "[   ab][ab]"},
+		{name: "unknown function", format: "{{quote .Name}}", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tpl, err := parseFormat(c.format, workDir)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error: %v, want error: %t", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := tpl.Execute(&buf, pkg); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != c.want {
+				t.Errorf("got: %q, want: %q", got, c.want)
+			}
+		})
+	}
+}
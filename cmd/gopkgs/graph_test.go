@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestRenderDiagram(t *testing.T) {
+	pkgs := map[string]gopkgs.Pkg{
+		"/work/m":     {ImportPath: "example.com/m", Imports: []string{"example.com/m/a", "fmt"}},
+		"/work/m/a":   {ImportPath: "example.com/m/a", Imports: []string{`example.com/m/"q"<b>`}},
+		"/work/m/q_b": {ImportPath: `example.com/m/"q"<b>`},
+		"/work/x/y":   {ImportPath: "example.com/x/y", Imports: []string{"example.com/m"}},
+	}
+
+	cases := []struct {
+		name     string
+		render   func(io.Writer, diagram) error
+		imports  bool
+		patterns []string
+		want     string
+	}{
+		{
+			name:     "dot tree",
+			render:   renderDOT,
+			patterns: []string{"example.com/m/..."},
+			want: `digraph gopkgs {
+	rankdir=LR;
+	node [shape=box];
+	"example.com" [label="example.com"];
+	"example.com/m" [label="m"];
+	"example.com/m/\"q\"<b>" [label="\"q\"<b>"];
+	"example.com/m/a" [label="a"];
+	"example.com" -> "example.com/m";
+	"example.com/m" -> "example.com/m/\"q\"<b>";
+	"example.com/m" -> "example.com/m/a";
+}
+`,
+		},
+		{
+			name:    "dot imports",
+			render:  renderDOT,
+			imports: true,
+			want: `digraph gopkgs {
+	rankdir=LR;
+	node [shape=box];
+	"example.com/m" [label="example.com/m"];
+	"example.com/m/\"q\"<b>" [label="example.com/m/\"q\"<b>"];
+	"example.com/m/a" [label="example.com/m/a"];
+	"example.com/x/y" [label="example.com/x/y"];
+	"example.com/m" -> "example.com/m/a";
+	"example.com/m/a" -> "example.com/m/\"q\"<b>";
+	"example.com/x/y" -> "example.com/m";
+}
+`,
+		},
+		{
+			name:     "mermaid tree",
+			render:   renderMermaid,
+			patterns: []string{"example.com/x/y", "example.com/m/a"},
+			want: `graph LR
+	n0["example.com"]
+	n1["m"]
+	n2["a"]
+	n3["x"]
+	n4["y"]
+	n0 --> n1
+	n0 --> n3
+	n1 --> n2
+	n3 --> n4
+`,
+		},
+		{
+			name:     "mermaid imports",
+			render:   renderMermaid,
+			imports:  true,
+			patterns: []string{"example.com/m/..."},
+			want: `graph LR
+	n0["example.com/m"]
+	n1["example.com/m/#quot;q#quot;#lt;b#gt;"]
+	n2["example.com/m/a"]
+	n0 --> n2
+	n2 --> n1
+`,
+		},
+		{
+			name:     "no match",
+			render:   renderMermaid,
+			patterns: []string{"golang.org/..."},
+			want:     "graph LR\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matched := filterPkgs(pkgs, c.patterns)
+
+			var d diagram
+			if c.imports {
+				d = importDiagram(matched)
+			} else {
+				d = treeDiagram(matched)
+			}
+
+			var buf bytes.Buffer
+			if err := c.render(&buf, d); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	ix := testIndex(
+		gopkgs.Pkg{ImportPath: "encoding/json", Name: "json", Standard: true},
+		gopkgs.Pkg{ImportPath: "net/http", Name: "http", Standard: true},
+		gopkgs.Pkg{ImportPath: "net/http/httptest", Name: "httptest", Standard: true},
+		gopkgs.Pkg{ImportPath: "net/url", Name: "url", Standard: true},
+		gopkgs.Pkg{ImportPath: "github.com/x/jsonx", Name: "jsonx"},
+	)
+	srv := httptest.NewServer(newHTTPHandler(ix))
+	defer srv.Close()
+
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantPaths []string // import paths of the packages returned, in order
+		wantPkg   string   // import path of the single package returned
+		wantAllow string
+	}{
+		{name: "packages", method: "GET", path: "/packages", wantCode: 200, wantPaths: []string{"encoding/json", "github.com/x/jsonx", "net/http", "net/http/httptest", "net/url"}},
+		{name: "pattern", method: "GET", path: "/packages?pattern=net/...", wantCode: 200, wantPaths: []string{"net/http", "net/http/httptest", "net/url"}},
+		{name: "exact pattern", method: "GET", path: "/packages?pattern=net/http", wantCode: 200, wantPaths: []string{"net/http"}},
+		{name: "pattern without match", method: "GET", path: "/packages?pattern=os/...", wantCode: 200, wantPaths: []string{}},
+		{name: "packages method", method: "POST", path: "/packages", wantCode: 405, wantAllow: "GET"},
+		{name: "search", method: "GET", path: "/search?q=json", wantCode: 200, wantPaths: []string{"encoding/json", "github.com/x/jsonx"}},
+		{name: "search limit", method: "GET", path: "/search?q=json&limit=1", wantCode: 200, wantPaths: []string{"encoding/json"}},
+		{name: "search zero limit", method: "GET", path: "/search?q=http&limit=0", wantCode: 200, wantPaths: []string{"net/http", "net/http/httptest"}},
+		{name: "search invalid limit", method: "GET", path: "/search?q=json&limit=ten", wantCode: 400},
+		{name: "search negative limit", method: "GET", path: "/search?q=json&limit=-1", wantCode: 400},
+		{name: "package", method: "GET", path: "/package/net/http/httptest", wantCode: 200, wantPkg: "net/http/httptest"},
+		{name: "package head", method: "HEAD", path: "/package/net/url", wantCode: 200},
+		{name: "unknown package", method: "GET", path: "/package/net/nosuch", wantCode: 404},
+		{name: "refresh method", method: "GET", path: "/refresh", wantCode: 405, wantAllow: "POST"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(c.method, srv.URL+c.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != c.wantCode {
+				t.Fatalf("got status: %d, want: %d", resp.StatusCode, c.wantCode)
+			}
+
+			if allow := resp.Header.Get("Allow"); allow != c.wantAllow {
+				t.Errorf("got Allow: %q, want: %q", allow, c.wantAllow)
+			}
+
+			if c.method == "HEAD" {
+				return
+			}
+
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("got Content-Type: %q, want: application/json", ct)
+			}
+
+			switch {
+			case c.wantCode != 200:
+				var body map[string]string
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["error"] == "" {
+					t.Errorf("got error body: %v, %v, want an error message", body, err)
+				}
+			case c.wantPaths != nil:
+				var pkgs []gopkgs.Pkg
+				if err := json.NewDecoder(resp.Body).Decode(&pkgs); err != nil {
+					t.Fatal(err)
+				}
+
+				paths := []string{}
+				for _, pkg := range pkgs {
+					paths = append(paths, pkg.ImportPath)
+				}
+
+				if !reflect.DeepEqual(paths, c.wantPaths) {
+					t.Errorf("got: %v, want: %v", paths, c.wantPaths)
+				}
+			default:
+				var pkg gopkgs.Pkg
+				if err := json.NewDecoder(resp.Body).Decode(&pkg); err != nil {
+					t.Fatal(err)
+				}
+
+				if pkg.ImportPath != c.wantPkg {
+					t.Errorf("got: %s, want: %s", pkg.ImportPath, c.wantPkg)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPRefresh(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{"lib/lib.go": "package lib\n"})
+	ix := newIndex(gopkgs.Options{WorkDir: dir})
+	if err := ix.ensure(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, map[string]string{"lib/sub/sub.go": "package sub\n"})
+	srv := httptest.NewServer(newHTTPHandler(ix))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/refresh", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got refreshResult
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"example.com/m/lib/sub"}; !reflect.DeepEqual(got.Added, want) || len(got.Removed) != 0 {
+		t.Errorf("got added: %v, removed: %v, want added: %v", got.Added, got.Removed, want)
+	}
+
+	if _, found := ix.get("example.com/m/lib/sub"); !found {
+		t.Error("refreshed package not on the index")
+	}
+}
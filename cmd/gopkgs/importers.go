@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func runImporters(args []string) error {
+	fs := newFlagSet("importers")
+	lf := addListFlags(fs)
+	recursive := fs.Bool("r", false, "include the indirect importers")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	g, importPath, err := loadGraph(fs.Args(), lf)
+	if err != nil {
+		return err
+	}
+
+	if *recursive {
+		return printLines(g.ReverseDeps(importPath))
+	}
+	return printLines(g.Importers(importPath))
+}
+
+func runDeps(args []string) error {
+	fs := newFlagSet("deps")
+	lf := addListFlags(fs)
+	direct := fs.Bool("direct", false, "only the direct imports")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	g, importPath, err := loadGraph(fs.Args(), lf)
+	if err != nil {
+		return err
+	}
+
+	if *direct {
+		return printLines(g.Imports(importPath))
+	}
+	return printLines(g.Deps(importPath))
+}
+
+func loadGraph(args []string, lf listFlags) (*gopkgs.Graph, string, error) {
+	if len(args) != 1 {
+		return nil, "", errors.New("expect exactly one import path")
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.WorkDir == "" {
+		if opts.WorkDir, err = filepath.Abs("."); err != nil {
+			return nil, "", err
+		}
+	}
+
+	opts.Imports = true
+	opts.IncludeMain = true
+	pkgs, err := gopkgs.List(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	g := gopkgs.NewGraph(pkgs)
+	importPath := args[0]
+	for _, p := range g.Packages() {
+		if p == importPath {
+			return g, importPath, nil
+		}
+	}
+	return nil, "", fmt.Errorf("package %s not found", importPath)
+}
+
+func printLines(lines []string) error {
+	w := bufio.NewWriter(os.Stdout)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return w.Flush()
+}
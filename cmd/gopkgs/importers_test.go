@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestRunImporters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{
+		"cmd/app/main.go":       "package main\n\nimport _ \"example.com/m/internal/http\"\n\nfunc main() {}\n",
+		"internal/http/http.go": "package http\n\nimport _ \"example.com/m/internal/store\"\n",
+		"internal/store/s.go":   "package store\n",
+	})
+
+	cases := []struct {
+		name string
+		run  func([]string) error
+		args []string
+		want string
+	}{
+		{
+			name: "main importer",
+			run:  runImporters,
+			args: []string{"-workDir", dir, "example.com/m/internal/http"},
+			want: "example.com/m/cmd/app\n",
+		},
+		{
+			name: "recursive",
+			run:  runImporters,
+			args: []string{"-r", "-workDir", dir, "example.com/m/internal/store"},
+			want: "example.com/m/cmd/app\nexample.com/m/internal/http\n",
+		},
+		{
+			name: "default workDir",
+			run:  runImporters,
+			args: []string{"example.com/m/internal/store"},
+			want: "example.com/m/internal/http\n",
+		},
+		{
+			name: "deps of main",
+			run:  runDeps,
+			args: []string{"example.com/m/cmd/app"},
+			want: "example.com/m/internal/http\nexample.com/m/internal/store\n",
+		},
+	}
+
+	defer chdir(t, dir)()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := captureStdout(t, func() error { return c.run(c.args) })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Import groups, in the order they appear on the import declaration.
+const (
+	stdGroup = iota
+	thirdPartyGroup
+	localGroup
+)
+
+// importGroup returns the group of the import path, local being the packages of the module.
+func importGroup(importPath, modPath string) int {
+	switch {
+	case modPath != "" && (importPath == modPath || strings.HasPrefix(importPath, modPath+"/")):
+		return localGroup
+	case !strings.Contains(strings.SplitN(importPath, "/", 2)[0], "."):
+		return stdGroup
+	}
+	return thirdPartyGroup
+}
+
+// importName returns the name to import the package with, empty if the package name is
+// the one assumed from the import path.
+func importName(importPath, pkgName string) string {
+	if assumedName(importPath) == pkgName {
+		return ""
+	}
+	return pkgName
+}
+
+// assumedName returns the package name assumed from the import path, same as goimports:
+// the last element other than the major version suffix, without "go-" prefix, up to the
+// first character invalid on identifier. E.g. yaml for gopkg.in/yaml.v2 and pkg for
+// example.com/go-pkg/v2.
+func assumedName(importPath string) string {
+	base := path.Base(importPath)
+	if isMajorVersion(base) {
+		base = path.Base(path.Dir(importPath))
+	}
+
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+
+	n, err := strconv.Atoi(s[1:])
+	return err == nil && n >= 2
+}
+
+// addImportFile adds the import to the Go file, returning false if it is already imported.
+func addImportFile(filename, name, importPath string) (bool, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	modPath, _ := fileModulePath(filepath.Dir(filename))
+	out, added, err := addImport(src, name, importPath, modPath)
+	if err != nil || !added {
+		return added, err
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(filename, out, fi.Mode().Perm())
+}
+
+// addImport adds the import to the source, keeping the standard library, third party and
+// local (under modPath) imports on separate groups. The first import declaration is
+// rewritten, the source being formatted afterward.
+func addImport(src []byte, name, importPath, modPath string) ([]byte, bool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.ImportsOnly)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, spec := range f.Imports {
+		if p, _ := strconv.Unquote(spec.Path.Value); p == importPath {
+			return src, false, nil
+		}
+	}
+
+	newSpec := strconv.Quote(importPath)
+	if name != "" {
+		newSpec = name + " " + newSpec
+	}
+
+	// the first import declaration, other than import "C" along with its cgo preamble
+	var decl, last *ast.GenDecl
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+
+		last = gd
+		if !importsCgo(gd) {
+			decl = gd
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if decl == nil {
+		// after the line of the package clause, or of the import "C"
+		end := f.Name.End()
+		if last != nil {
+			end = last.End()
+		}
+		offset := lineEnd(fset, f, src, end)
+
+		buf.Write(src[:offset])
+		buf.WriteString("\n\nimport " + newSpec + "\n")
+		buf.Write(src[offset:])
+	} else {
+		buf.WriteString(importDecl(fset, src, decl, newSpec, importGroup(importPath, modPath), modPath))
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// lineEnd returns the offset of the end of the line of pos, after the comments starting on
+// that line, e.g. the import comment of package m // import "example.com/m".
+func lineEnd(fset *token.FileSet, f *ast.File, src []byte, pos token.Pos) int {
+	line := fset.Position(pos).Line
+	end := fset.Position(pos).Offset
+	for _, cg := range f.Comments {
+		if cg.Pos() < pos {
+			continue
+		}
+
+		for _, c := range cg.List {
+			if fset.Position(c.Pos()).Line != line {
+				break
+			}
+			end = fset.Position(c.End()).Offset
+			line = fset.Position(c.End()).Line
+		}
+	}
+
+	if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+		return end + i
+	}
+	return len(src)
+}
+
+func importsCgo(decl *ast.GenDecl) bool {
+	for _, s := range decl.Specs {
+		if s.(*ast.ImportSpec).Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+// specGroup is the specs of an import declaration not separated by blank line.
+type specGroup struct {
+	group  int
+	starts []int // source offset of the spec, along with its doc comment
+	ends   []int // source offset of the spec end, along with its line comment
+	paths  []string
+}
+
+// importDecl returns the source with the new spec inserted on the group of the import
+// declaration. The spec is spliced into the declaration, keeping its comments wherever they
+// are, the declaration without parentheses getting them.
+func importDecl(fset *token.FileSet, src []byte, decl *ast.GenDecl, newSpec string, group int, modPath string) string {
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	var groups []*specGroup
+	lastLine := 0
+	declEnd := offset(decl.End())
+	for _, s := range decl.Specs {
+		spec := s.(*ast.ImportSpec)
+		start, end := spec.Pos(), spec.End()
+		if spec.Doc != nil {
+			start = spec.Doc.Pos()
+		}
+		if spec.Comment != nil {
+			end = spec.Comment.End()
+		}
+
+		p, _ := strconv.Unquote(spec.Path.Value)
+		line := fset.Position(start).Line
+		if len(groups) == 0 || line > lastLine+1 {
+			groups = append(groups, &specGroup{group: importGroup(p, modPath)})
+		}
+		lastLine = fset.Position(end).Line
+
+		g := groups[len(groups)-1]
+		g.starts = append(g.starts, offset(start))
+		g.ends = append(g.ends, offset(end))
+		g.paths = append(g.paths, p)
+		if offset(end) > declEnd {
+			// the line comment of import "path" without parentheses
+			declEnd = offset(end)
+		}
+	}
+
+	newPath := newSpec[strings.IndexByte(newSpec, '"'):]
+	newPath, _ = strconv.Unquote(newPath)
+
+	target := -1
+	for i, g := range groups {
+		if g.group == group {
+			target = i
+		}
+	}
+
+	var at int
+	var insert string
+	switch {
+	case target >= 0:
+		g := groups[target]
+		i := sort.Search(len(g.paths), func(i int) bool { return g.paths[i] > newPath })
+		if !sort.StringsAreSorted(g.paths) {
+			i = len(g.paths)
+		}
+
+		if i < len(g.paths) {
+			at, insert = g.starts[i], newSpec+"\n\t"
+		} else {
+			at, insert = g.ends[i-1], "\n\t"+newSpec
+		}
+	case len(groups) > 0:
+		i := 0
+		for i < len(groups) && groups[i].group <= group {
+			i++
+		}
+
+		if i < len(groups) {
+			at, insert = groups[i].starts[0], newSpec+"\n\n\t"
+		} else {
+			last := groups[len(groups)-1]
+			at, insert = last.ends[len(last.ends)-1], "\n\n\t"+newSpec
+		}
+	default:
+		// import ()
+		at, insert = offset(decl.Rparen), "\n\t"+newSpec+"\n"
+	}
+
+	if decl.Lparen.IsValid() {
+		return string(src[:at]) + insert + string(src[at:])
+	}
+
+	declStart := offset(decl.Pos())
+	spec := string(src[declStart:at]) + insert + string(src[at:declEnd])
+	spec = strings.TrimSpace(strings.TrimPrefix(spec, "import"))
+	return string(src[:declStart]) + "import (\n\t" + spec + "\n)" + string(src[declEnd:])
+}
+
+// fileModulePath returns the path of the module containing dir and the import path of dir,
+// empty if there is no module.
+func fileModulePath(dir string) (modPath, pkgPath string) {
+	for d := dir; ; {
+		if data, err := ioutil.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			s := bufio.NewScanner(bytes.NewReader(data))
+			for s.Scan() {
+				fields := strings.Fields(s.Text())
+				if len(fields) >= 2 && fields[0] == "module" {
+					modPath = strings.Trim(fields[1], `"`)
+					break
+				}
+			}
+
+			if modPath == "" {
+				return "", ""
+			}
+
+			rel, err := filepath.Rel(d, dir)
+			if err != nil || rel == "." {
+				return modPath, modPath
+			}
+			return modPath, path.Join(modPath, filepath.ToSlash(rel))
+		}
+
+		parent := filepath.Dir(d)
+		if parent == d {
+			return "", ""
+		}
+		d = parent
+	}
+}
+
+// importable reports whether the package of pkgPath may import the package, not being
+// internal to another tree nor vendored, the vendored packages being imported by the path
+// after vendor/. The standard library internal packages are importable from the standard
+// library only, pkgPath being empty for the packages out of module.
+func importable(importPath, pkgPath string) bool {
+	if importPath == "vendor" || strings.HasPrefix(importPath, "vendor/") || strings.Contains(importPath, "/vendor/") {
+		return false
+	}
+
+	i := strings.LastIndex(importPath, "/internal/")
+	switch {
+	case i >= 0:
+	case strings.HasSuffix(importPath, "/internal"):
+		i = len(importPath) - len("/internal")
+	case importPath == "internal" || strings.HasPrefix(importPath, "internal/"):
+		return false
+	default:
+		return true
+	}
+
+	parent := importPath[:i]
+	return pkgPath == parent || strings.HasPrefix(pkgPath, parent+"/")
+}
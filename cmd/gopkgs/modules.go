@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var modulesUsageInfo = `
+Lists the modules on the build list of workDir (current directory by default), or with -cached
+the module versions downloaded in the module cache ($GOMODCACHE), whether required or not.
+With -proxy, lists the module versions of the local GOPROXY, the first file:// entry of
+go env GOPROXY, the packages being read from the module zips without extracting them.
+
+The arguments select the modules, either by path pattern or by path@version, e.g.
+	gopkgs modules -cached golang.org/x/...
+	gopkgs modules -cached -pkgs golang.org/x/tools@v0.1.0
+
+Use -format to custom the output, the struct being passed to template is:
+	type Module struct {
+		Path    string // module path
+		Version string // module version, empty for the main modules
+		Dir     string // directory holding the module sources, empty if not downloaded
+
+		// Only for the modules of a local GOPROXY (-proxy)
+		Time  time.Time // version time, from the .info file
+		GoMod string    // path of the .mod file
+		Zip   string    // path of the module zip
+	}
+With -pkgs, the packages of the selected modules are printed instead, the template being
+passed the same Pkg struct as gopkgs.
+`
+
+func runModules(args []string) error {
+	fs := newFlagSet("modules")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["modules"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, modulesUsageInfo)
+	}
+	lf := addListFlags(fs)
+	cached := fs.Bool("cached", false, "list the module versions in the module cache instead of the build list")
+	proxy := fs.Bool("proxy", false, "list the module versions in the local GOPROXY (file://) instead of the build list")
+	listPkgs := fs.Bool("pkgs", false, "list the packages of the selected modules")
+	format := fs.String("format", "", "custom output format, columns of path, version and dir (or zip) by default")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		if opts.WorkDir, err = filepath.Abs("."); err != nil {
+			return err
+		}
+	}
+
+	if *cached && *proxy {
+		return errors.New("only one of -cached and -proxy can be used")
+	}
+
+	var mods []gopkgs.Module
+	switch {
+	case *cached:
+		mods, err = gopkgs.ListModCache(opts)
+	case *proxy:
+		mods, err = gopkgs.ListProxyModules(opts)
+	default:
+		mods, err = gopkgs.ListModules(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	mods = selectModules(mods, fs.Args())
+
+	w := bufio.NewWriter(os.Stdout)
+	if *listPkgs {
+		if err := printModulePkgs(w, opts, mods, *format); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	if *format != "" {
+		tpl, err := parseFormat(*format, opts.WorkDir)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range mods {
+			if err := tpl.Execute(w, m); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return w.Flush()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, m := range mods {
+		dir := m.Dir
+		if dir == "" {
+			dir = m.Zip
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Path, m.Version, dir)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// selectModules returns the modules selected by the args, path patterns or path@version.
+// All modules are selected if there is no args.
+func selectModules(mods []gopkgs.Module, args []string) []gopkgs.Module {
+	if len(args) == 0 {
+		return mods
+	}
+
+	var out []gopkgs.Module
+	for _, m := range mods {
+		for _, arg := range args {
+			path, version := arg, ""
+			if i := strings.LastIndexByte(arg, '@'); i >= 0 {
+				path, version = arg[:i], arg[i+1:]
+			}
+
+			if gopkgs.MatchPattern(path)(m.Path) && (version == "" || version == m.Version) {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func printModulePkgs(w *bufio.Writer, opts gopkgs.Options, mods []gopkgs.Module, format string) error {
+	if format == "" {
+		format = "{{.ImportPath}}"
+	}
+
+	tpl, err := parseFormat(format, opts.WorkDir)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mods {
+		if m.Dir == "" && m.Zip == "" {
+			// module not downloaded
+			continue
+		}
+
+		pkgs, err := gopkgs.ListModule(opts, m)
+		if err != nil {
+			return err
+		}
+
+		for _, pkg := range sortedPkgs(pkgs) {
+			if err := tpl.Execute(w, pkg); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
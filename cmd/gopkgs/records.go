@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+const defaultFields = "ImportPath,Name,Dir,Standard"
+
+// writeFormat writes the packages formatted by tpl, each output followed by terminator.
+func writeFormat(w io.Writer, pkgs map[string]gopkgs.Pkg, tpl *template.Template, terminator string) error {
+	for _, pkg := range pkgs {
+		if err := tpl.Execute(w, pkg); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, terminator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldIndexes resolves the comma separated Pkg field names.
+func fieldIndexes(fields string) ([]string, [][]int, error) {
+	t := reflect.TypeOf(gopkgs.Pkg{})
+	names := strings.Split(fields, ",")
+	indexes := make([][]int, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown field %q", name)
+		}
+		names[i] = name
+		indexes[i] = f.Index
+	}
+	return names, indexes, nil
+}
+
+// fieldValue formats the value of the field, lists are separated by space.
+func fieldValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Slice:
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = fieldValue(v.Index(i))
+		}
+		return strings.Join(elems, " ")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func pkgRecord(pkg gopkgs.Pkg, indexes [][]int) []string {
+	v := reflect.ValueOf(pkg)
+	record := make([]string, len(indexes))
+	for i, index := range indexes {
+		record[i] = fieldValue(v.FieldByIndex(index))
+	}
+	return record
+}
+
+// writeCSV writes the packages as CSV with a header row.
+func writeCSV(w io.Writer, pkgs map[string]gopkgs.Pkg, fields string) error {
+	names, indexes, err := fieldIndexes(fields)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err = cw.Write(names); err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		if err = cw.Write(pkgRecord(pkg, indexes)); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// tsvEscaper escapes the characters having special meaning in TSV.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// writeTSV writes the packages as TSV with a header row. Backslash, tab and newline characters
+// on the values are escaped as \\, \t and \n.
+func writeTSV(w io.Writer, pkgs map[string]gopkgs.Pkg, fields string) error {
+	names, indexes, err := fieldIndexes(fields)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintln(w, strings.Join(names, "\t")); err != nil {
+		return err
+	}
+
+	for _, pkg := range pkgs {
+		record := pkgRecord(pkg, indexes)
+		for i := range record {
+			record[i] = tsvEscaper.Replace(record[i])
+		}
+
+		if _, err = fmt.Fprintln(w, strings.Join(record, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestWriteRecords(t *testing.T) {
+	cases := []struct {
+		name    string
+		write   func(w io.Writer, pkgs map[string]gopkgs.Pkg, fields string) error
+		fields  string
+		pkg     gopkgs.Pkg
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "csv",
+			write:  writeCSV,
+			fields: defaultFields,
+			pkg:    gopkgs.Pkg{ImportPath: "example.com/m", Name: "m", Dir: "/src/m"},
+			want:   "ImportPath,Name,Dir,Standard\nexample.com/m,m,/src/m,false\n",
+		},
+		{
+			name:   "csv quoting",
+			write:  writeCSV,
+			fields: "Dir, Standard",
+			pkg:    gopkgs.Pkg{Dir: `/src/a,b "c"` + "\td\ne", Standard: true},
+			want:   "Dir,Standard\n\"/src/a,b \"\"c\"\"\td\ne\",true\n",
+		},
+		{
+			name:   "csv list field",
+			write:  writeCSV,
+			fields: "ImportPath,GoFiles",
+			pkg:    gopkgs.Pkg{ImportPath: "example.com/m", GoFiles: []string{"a.go", "b.go"}},
+			want:   "ImportPath,GoFiles\nexample.com/m,a.go b.go\n",
+		},
+		{
+			name:    "csv unknown field",
+			write:   writeCSV,
+			fields:  "ImportPath,Path",
+			wantErr: true,
+		},
+		{
+			name:   "tsv",
+			write:  writeTSV,
+			fields: defaultFields,
+			pkg:    gopkgs.Pkg{ImportPath: "example.com/m", Name: "m", Dir: "/src/m"},
+			want:   "ImportPath\tName\tDir\tStandard\nexample.com/m\tm\t/src/m\tfalse\n",
+		},
+		{
+			name:   "tsv escaping",
+			write:  writeTSV,
+			fields: "Dir,Name",
+			pkg:    gopkgs.Pkg{Dir: `/src/a,b "c"` + "\td\ne\r" + `\f`, Name: "m"},
+			want:   "Dir\tName\n/src/a,b \"c\"\\td\\ne\\r\\\\f\tm\n",
+		},
+		{
+			name:    "tsv unknown field",
+			write:   writeTSV,
+			fields:  "Path",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := c.write(&buf, map[string]gopkgs.Pkg{c.pkg.Dir: c.pkg}, c.fields)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error: %v, want error: %t", err, c.wantErr)
+			}
+
+			if got := buf.String(); !c.wantErr && got != c.want {
+				t.Errorf("got: %q, want: %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteFormat(t *testing.T) {
+	pkgs := map[string]gopkgs.Pkg{
+		"/src/a": {ImportPath: "example.com/a", Dir: "/src/a"},
+		"/src/b": {ImportPath: "example.com/b\nc", Dir: "/src/b"},
+	}
+
+	tpl, err := parseFormat("{{.ImportPath}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		terminator string
+		want       []string
+	}{
+		{name: "newline", terminator: "\n", want: []string{"", "c", "example.com/a", "example.com/b"}},
+		{name: "nul", terminator: "\x00", want: []string{"", "example.com/a", "example.com/b\nc"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeFormat(&buf, pkgs, tpl, c.terminator); err != nil {
+				t.Fatal(err)
+			}
+
+			got := strings.Split(buf.String(), c.terminator)
+			sort.Strings(got)
+			if strings.Join(got, "|") != strings.Join(c.want, "|") {
+				t.Errorf("got: %q, want: %q", got, c.want)
+			}
+		})
+	}
+}
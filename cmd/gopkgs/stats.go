@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// printStats writes the details of the listing.
+func printStats(w io.Writer, result *gopkgs.ListResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "mode:\t%s\n", result.Mode)
+	fmt.Fprintf(tw, "roots:\t%d\n", len(result.Roots))
+	for _, root := range result.Roots {
+		fmt.Fprintf(tw, "\t  %s\n", root)
+	}
+	fmt.Fprintf(tw, "packages:\t%d\n", len(result.Pkgs))
+	fmt.Fprintf(tw, "dirs visited:\t%d\n", result.DirsVisited)
+	fmt.Fprintf(tw, "files read:\t%d\n", result.FilesRead)
+	fmt.Fprintf(tw, "go list -m:\t%v\n", result.ModListDuration)
+	fmt.Fprintf(tw, "walk:\t%v\n", result.WalkDuration)
+	fmt.Fprintf(tw, "files:\t%v\n", result.FilesDuration)
+	fmt.Fprintf(tw, "total:\t%v\n", result.Duration)
+	return tw.Flush()
+}
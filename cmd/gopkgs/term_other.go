@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "errors"
+
+var errNoRawTerminal = errors.New("raw terminal is not supported on this platform")
+
+// makeRaw reports the raw terminal is not supported, the line mode being used instead.
+func makeRaw(fd int) (func() error, error) {
+	return nil, errNoRawTerminal
+}
+
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errNoRawTerminal
+}
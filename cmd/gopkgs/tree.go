@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// treeNode is an element of the import path hierarchy.
+type treeNode struct {
+	name     string
+	count    int // number of packages under the node, including itself
+	children map[string]*treeNode
+}
+
+func newTreeNode(name string) *treeNode {
+	return &treeNode{name: name, children: make(map[string]*treeNode)}
+}
+
+func (n *treeNode) add(elems []string) {
+	n.count++
+	if len(elems) == 0 {
+		return
+	}
+
+	child, found := n.children[elems[0]]
+	if !found {
+		child = newTreeNode(elems[0])
+		n.children[elems[0]] = child
+	}
+	child.add(elems[1:])
+}
+
+// buildTree groups the packages by standard library, module and GOPATH root, then by import path elements.
+func buildTree(pkgs map[string]gopkgs.Pkg) *treeNode {
+	root := newTreeNode("")
+	for _, pkg := range pkgs {
+		group, rel := treeGroup(pkg)
+		var elems []string
+		if rel != "" {
+			elems = strings.Split(rel, "/")
+		}
+		root.add(append([]string{group}, elems...))
+	}
+	return root
+}
+
+func treeGroup(pkg gopkgs.Pkg) (group, rel string) {
+	switch {
+	case pkg.Standard:
+		return "std", pkg.ImportPath
+	case pkg.Module != "":
+		rel = strings.TrimPrefix(strings.TrimPrefix(pkg.ImportPath, pkg.Module), "/")
+		return pkg.Module, rel
+	default:
+		return pkg.Root, pkg.ImportPath
+	}
+}
+
+// printTree writes the children of n, indented by their level. depth limits the levels
+// printed, 0 means no limit.
+func printTree(w io.Writer, n *treeNode, depth int) error {
+	return n.printChildren(w, 0, depth)
+}
+
+func (n *treeNode) printChildren(w io.Writer, level, depth int) error {
+	if depth > 0 && level >= depth {
+		return nil
+	}
+
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := n.children[name]
+		if _, err := fmt.Fprintf(w, "%s%s (%d)\n", strings.Repeat("  ", level), child.name, child.count); err != nil {
+			return err
+		}
+
+		if err := child.printChildren(w, level+1, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
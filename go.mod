@@ -1,8 +1,5 @@
 module github.com/uudashr/gopkgs/v2
 
-go 1.11
+go 1.16
 
-require (
-	github.com/karrick/godirwalk v1.12.0
-	github.com/pkg/errors v0.8.1
-)
+require golang.org/x/mod v0.4.2
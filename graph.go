@@ -0,0 +1,51 @@
+package gopkgs // import "github.com/uudashr/gopkgs/v2"
+
+import (
+	"github.com/uudashr/gopkgs/v2/internal"
+)
+
+// Graph is the import graph of listed packages, keyed by import path.
+type Graph struct {
+	g *internal.Graph
+}
+
+// NewGraph builds the import graph of pkgs. The packages must be retrieved with Options.Imports.
+func NewGraph(pkgs map[string]Pkg) *Graph {
+	result := make(map[string]internal.Pkg, len(pkgs))
+	for key, pkg := range pkgs {
+		result[key] = internal.Pkg(pkg)
+	}
+	return &Graph{g: internal.NewGraph(result)}
+}
+
+// Packages returns the sorted import paths on the graph.
+func (g *Graph) Packages() []string {
+	return g.g.Packages()
+}
+
+// Imports returns the direct imports of importPath which are on the graph.
+func (g *Graph) Imports(importPath string) []string {
+	return g.g.Imports(importPath)
+}
+
+// Importers returns the packages directly importing importPath.
+func (g *Graph) Importers(importPath string) []string {
+	return g.g.Importers(importPath)
+}
+
+// Deps returns the packages importPath depends on, directly or indirectly.
+func (g *Graph) Deps(importPath string) []string {
+	return g.g.Deps(importPath)
+}
+
+// ReverseDeps returns the packages depending on importPath, directly or indirectly.
+func (g *Graph) ReverseDeps(importPath string) []string {
+	return g.g.ReverseDeps(importPath)
+}
+
+// Cycles returns the import cycles on the graph, one for each set of packages
+// importing each other. A cycle is reported as its shortest path starting and
+// ending on the lowest sorted package in the set, e.g. [a b c a].
+func (g *Graph) Cycles() [][]string {
+	return g.g.Cycles()
+}
@@ -0,0 +1,145 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// readAllPkgFiles fills the file listing and/or the imports of pkgs, reading the
+// packages concurrently.
+func readAllPkgFiles(e *env, pkgs map[string]Pkg, opts Options, concurrency int) error {
+	list := make([]Pkg, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		list = append(list, pkg)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		errs = make([]error, len(list))
+	)
+
+	for i := range list {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs[i] = readPkgFiles(e, &list[i], opts.Files, opts.Imports)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, pkg := range list {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		pkgs[pkg.Dir] = pkg
+	}
+	return nil
+}
+
+// readPkgFiles fills the file listing and/or the imports of pkg by classifying
+// the .go files on pkg.Dir, using the build constraints of the build context.
+func readPkgFiles(e *env, pkg *Pkg, files, imports bool) error {
+	des, err := e.readDir(pkg.Dir)
+	if err != nil {
+		return err
+	}
+
+	var pkgFiles Pkg
+	importSet := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || name[0] == '.' || name[0] == '_' || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		match, err := e.ctxt.MatchFile(pkg.Dir, name)
+		if err != nil {
+			// skip unreadable file
+			continue
+		}
+
+		if !match {
+			pkgFiles.IgnoredFiles = append(pkgFiles.IgnoredFiles, name)
+			continue
+		}
+
+		filename := filepath.Join(pkg.Dir, name)
+		src, err := e.readFile(filename)
+		if err != nil {
+			// skip unreadable file
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filename, src, parser.ImportsOnly)
+		if err != nil {
+			// skip unparseable file
+			continue
+		}
+
+		if strings.HasSuffix(name, "_test.go") {
+			if pkgName := f.Name.Name; pkgName != pkg.Name && strings.HasSuffix(pkgName, "_test") {
+				pkgFiles.XTestGoFiles = append(pkgFiles.XTestGoFiles, name)
+				pkgFiles.XTestName = pkgName
+				continue
+			}
+
+			pkgFiles.TestGoFiles = append(pkgFiles.TestGoFiles, name)
+			continue
+		}
+
+		for _, spec := range f.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil && path != "C" {
+				importSet[path] = true
+			}
+		}
+
+		if importsC(f.Imports) {
+			pkgFiles.CgoFiles = append(pkgFiles.CgoFiles, name)
+			continue
+		}
+
+		pkgFiles.GoFiles = append(pkgFiles.GoFiles, name)
+	}
+
+	if files {
+		pkg.GoFiles = pkgFiles.GoFiles
+		pkg.CgoFiles = pkgFiles.CgoFiles
+		pkg.TestGoFiles = pkgFiles.TestGoFiles
+		pkg.XTestGoFiles = pkgFiles.XTestGoFiles
+		pkg.XTestName = pkgFiles.XTestName
+		pkg.IgnoredFiles = pkgFiles.IgnoredFiles
+	}
+
+	if imports {
+		pkg.Imports = make([]string, 0, len(importSet))
+		for path := range importSet {
+			pkg.Imports = append(pkg.Imports, path)
+		}
+		sort.Strings(pkg.Imports)
+	}
+
+	return nil
+}
+
+func importsC(specs []*ast.ImportSpec) bool {
+	for _, spec := range specs {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil && path == "C" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"go/build"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// env is the environment the packages are listed on.
+type env struct {
+	fsys fs.FS
+	ctxt build.Context // GOROOT and GOPATH, with the file system hooks using fsys
+
+	ignore      []ignoreRule // directories to skip, from Options.Exclude and the .gopkgsignore of workDir
+	ignoreDir   string       // directory whose .gopkgsignore is already on ignore
+	gitIgnore   bool         // honour the .gitignore files
+	includeMain bool         // list the main packages
+}
+
+func newEnv(opts Options) (*env, error) {
+	e := &env{
+		fsys:        opts.FS,
+		ctxt:        build.Default,
+		gitIgnore:   opts.GitIgnore,
+		includeMain: opts.IncludeMain,
+	}
+
+	if e.fsys == nil {
+		e.fsys = hostFS{}
+	}
+
+	if len(opts.Overlay) > 0 {
+		o, err := newOverlayFS(e.fsys, opts.Overlay)
+		if err != nil {
+			return nil, err
+		}
+		e.fsys = o
+	}
+
+	if opts.GOROOT != "" {
+		e.ctxt.GOROOT = opts.GOROOT
+	}
+
+	if opts.GOPATH != "" {
+		e.ctxt.GOPATH = opts.GOPATH
+	}
+
+	if opts.GOOS != "" {
+		e.ctxt.GOOS = opts.GOOS
+	}
+
+	if opts.GOARCH != "" {
+		e.ctxt.GOARCH = opts.GOARCH
+	}
+
+	for _, pattern := range opts.Exclude {
+		if rule, ok := parseIgnoreRule("", pattern); ok {
+			e.ignore = append(e.ignore, rule)
+		}
+	}
+
+	if opts.WorkDir != "" {
+		workDir, err := filepath.Abs(opts.WorkDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err := e.readFile(filepath.Join(workDir, gopkgsIgnoreFile)); err == nil {
+			e.ignore = append(e.ignore, parseIgnore(workDir, data)...)
+			e.ignoreDir = workDir
+		}
+	}
+
+	e.ctxt.IsDir = func(path string) bool {
+		fi, err := fs.Stat(e.fsys, fsName(path))
+		return err == nil && fi.IsDir()
+	}
+
+	e.ctxt.ReadDir = func(dir string) ([]os.FileInfo, error) {
+		des, err := fs.ReadDir(e.fsys, fsName(dir))
+		if err != nil {
+			return nil, err
+		}
+
+		infos := make([]os.FileInfo, 0, len(des))
+		for _, de := range des {
+			info, err := de.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	}
+
+	e.ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		return e.fsys.Open(fsName(path))
+	}
+
+	return e, nil
+}
+
+// readDir reads the entries of the directory, sorted by file name.
+func (e *env) readDir(dir string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(e.fsys, fsName(dir))
+}
+
+func (e *env) readFile(filename string) ([]byte, error) {
+	return fs.ReadFile(e.fsys, fsName(filename))
+}
+
+func (e *env) open(filename string) (fs.File, error) {
+	return e.fsys.Open(fsName(filename))
+}
+
+// fsName returns the name of the path on a fs.FS, which is an unrooted slash-separated path,
+// e.g. /usr/local/go is named usr/local/go.
+func fsName(path string) string {
+	name := strings.TrimLeft(filepath.ToSlash(path), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// hostFS is the file system of the host, the file names are the absolute paths as named by fsName.
+type hostFS struct{}
+
+func (hostFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	path := filepath.FromSlash(name)
+	if filepath.VolumeName(path) != "" {
+		// e.g. C:\Go on windows
+		return path, nil
+	}
+	return string(filepath.Separator) + path, nil
+}
+
+func (h hostFS) Open(name string) (fs.File, error) {
+	path, err := h.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (h hostFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := h.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(path)
+}
+
+func (h hostFS) ReadFile(name string) ([]byte, error) {
+	path, err := h.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (h hostFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := h.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(path)
+}
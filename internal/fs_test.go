@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestListFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"goroot/src/fmt/print.go":                           {Data: []byte("package fmt\n")},
+		"goroot/src/cmd/go/main.go":                         {Data: []byte("package main\n")},
+		"gopath/src/example.com/foo/foo.go":                 {Data: []byte("// Package foo.\npackage foo\n")},
+		"gopath/src/example.com/foo/foo_test.go":            {Data: []byte("package foo_test\n")},
+		"gopath/src/example.com/foo/testdata/bar/bar.go":    {Data: []byte("package bar\n")},
+		"gopath/src/example.com/foo/vendor/baz/baz.go":      {Data: []byte("package baz\n")},
+		"gopath/src/example.com/foo/.hidden/hidden.go":      {Data: []byte("package hidden\n")},
+		"gopath/src/example.com/foo/internal/qux/qux.go":    {Data: []byte("package qux\n\nimport \"fmt\"\n")},
+		"gopath/src/example.com/foo/internal/qux/README.md": {Data: []byte("# qux\n")},
+		"gopath/src/toplevel.go":                            {Data: []byte("package toplevel\n")},
+	}
+
+	pkgs, err := List(Options{
+		FS:      fsys,
+		GOROOT:  "/goroot",
+		GOPATH:  "/gopath",
+		Imports: true,
+	})
+	if err != nil {
+		t.Fatal("fail getting packages:", err)
+	}
+
+	want := map[string]Pkg{
+		"/goroot/src/fmt": {
+			Dir:        "/goroot/src/fmt",
+			ImportPath: "fmt",
+			Name:       "fmt",
+			Standard:   true,
+			Root:       "/goroot/src",
+			Imports:    []string{},
+		},
+		"/gopath/src/example.com/foo": {
+			Dir:        "/gopath/src/example.com/foo",
+			ImportPath: "example.com/foo",
+			Name:       "foo",
+			Root:       "/gopath/src",
+			Imports:    []string{},
+		},
+		"/gopath/src/example.com/foo/vendor/baz": {
+			Dir:        "/gopath/src/example.com/foo/vendor/baz",
+			ImportPath: "example.com/foo/vendor/baz",
+			Name:       "baz",
+			Root:       "/gopath/src",
+			Imports:    []string{},
+		},
+		"/gopath/src/example.com/foo/internal/qux": {
+			Dir:        "/gopath/src/example.com/foo/internal/qux",
+			ImportPath: "example.com/foo/internal/qux",
+			Name:       "qux",
+			Root:       "/gopath/src",
+			Imports:    []string{"fmt"},
+		},
+	}
+
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Error("got:", pkgs, "want:", want)
+	}
+}
+
+func TestFSName(t *testing.T) {
+	cases := []struct {
+		path string
+		name string
+	}{
+		{path: "/", name: "."},
+		{path: "/usr/local/go", name: "usr/local/go"},
+		{path: "/usr/local/go/src/fmt", name: "usr/local/go/src/fmt"},
+	}
+
+	for _, c := range cases {
+		if got, want := fsName(c.path), c.name; got != want {
+			t.Error("got:", got, "want:", want, "path:", c.path)
+		}
+	}
+}
@@ -4,41 +4,104 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
-	"go/build"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
-
-	"github.com/karrick/godirwalk"
-
-	pkgerrors "github.com/pkg/errors"
+	"sync"
+	"time"
 )
 
 const (
 	mainPkg        = "main"
 	testDataDir    = "testdata"
 	nodeModulesDir = "node_modules"
+	goModFile      = "go.mod"
 )
 
 // Pkg hold the information of the package.
 type Pkg struct {
-	Dir        string // directory containing package sources
-	ImportPath string // import path of package in dir
-	Name       string // package name
-	Standard   bool   // is this package part of the standard Go library?
+	Dir         string // directory containing package sources
+	ImportPath  string // import path of package in dir
+	Name        string // package name
+	Standard    bool   // is this package part of the standard Go library?
+	Root        string // root directory the package found on: GOROOT or GOPATH src directory, or module directory
+	Module      string // path of the module containing the package, only in module mode
+	Version     string // version of the module containing the package, if known
+	NotRequired bool   // is the module only in the module cache, not required by the main modules?
+
+	// File listing, only available when Options.Files is set
+	GoFiles      []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
+	CgoFiles     []string // .go source files that import "C"
+	TestGoFiles  []string // _test.go files in package
+	XTestGoFiles []string // _test.go files outside package
+	XTestName    string   // name of the external test package, if any
+	IgnoredFiles []string // .go source files ignored due to build constraints
+
+	Imports []string // import paths used by this package, only available when Options.Imports is set
 }
 
 // Options for retrieve packages.
 type Options struct {
-	WorkDir  string // Will return importable package under WorkDir. Any vendor dependencies outside the WorkDir will be ignored.
-	NoVendor bool   // Will not retrieve vendor dependencies, except inside WorkDir (if specified)
+	WorkDir     string // Will return importable package under WorkDir. Any vendor dependencies outside the WorkDir will be ignored.
+	NoVendor    bool   // Will not retrieve vendor dependencies, except inside WorkDir (if specified)
+	Files       bool   // Will retrieve the file listing of each package (GoFiles, TestGoFiles, etc)
+	Imports     bool   // Will retrieve the imports of each package
+	Concurrency int    // Number of directories walked concurrently, default to the number of CPUs
+
+	// File system to walk, the paths are resolved as unrooted slash-separated paths on it,
+	// e.g. /usr/local/go as usr/local/go. Default to the host file system.
+	// In module mode, the module directories are reported by the go command run on the host.
+	FS     fs.FS
+	GOROOT string // Will override the GOROOT of the default build context
+	GOPATH string // Will override the GOPATH of the default build context
+	GOOS   string // Will override the GOOS of the default build context, matching the files
+	GOARCH string // Will override the GOARCH of the default build context, matching the files
+
+	// Directories to skip, in gitignore syntax relative to each root, e.g. bazel-out or
+	// /third_party/js. The .gopkgsignore files on the roots, their sub directories and
+	// WorkDir are honoured the same way, the latter relative to WorkDir.
+	Exclude   []string
+	GitIgnore bool // Will also honour the .gitignore files
+
+	// Will also retrieve the main packages, e.g. for the import graph of the commands.
+	IncludeMain bool
+
+	// Will also retrieve the packages of the latest version of each module in the module cache
+	// not on the build list, marked as NotRequired. Only in module mode.
+	IncludeModCache bool
+
+	// Contents of the files to add or replace, keyed by absolute file path. Same as the
+	// overlay of golang.org/x/tools/go/packages, e.g. for the unsaved editor buffers.
+	Overlay map[string][]byte
+}
+
+// Listing modes.
+const (
+	ModeGOPATH = "gopath"
+	ModeModule = "module"
+)
+
+// ListResult is the packages along with the details of the listing.
+type ListResult struct {
+	Pkgs            map[string]Pkg // packages, keyed by directory
+	Mode            string         // listing mode, ModeGOPATH or ModeModule
+	Roots           []string       // GOROOT and GOPATH src directories, or module directories scanned
+	DirsVisited     int            // number of directories visited
+	FilesRead       int            // number of .go files read to find the package name
+	ModListDuration time.Duration  // duration of "go list -m", zero if there is no workDir
+	WalkDuration    time.Duration  // duration of walking the roots
+	FilesDuration   time.Duration  // duration of reading the file listing and imports, if requested
+	Duration        time.Duration  // total duration
 }
 
-type goFile struct {
-	path string
-	dir  string
+// stats counts the work done while walking a root.
+type stats struct {
+	dirs  int64
+	files int64
 }
 
 func mustClose(c io.Closer) {
@@ -47,8 +110,8 @@ func mustClose(c io.Closer) {
 	}
 }
 
-func readPackageName(filename string) (string, error) {
-	f, err := os.Open(filename)
+func readPackageName(e *env, filename string) (string, error) {
+	f, err := e.open(filename)
 	if err != nil {
 		return "", err
 	}
@@ -99,280 +162,232 @@ func readPackageName(filename string) (string, error) {
 	return "", errors.New("cannot find package information")
 }
 
-func listFiles(srcDir, workDir string, noVendor bool) (<-chan goFile, <-chan error) {
-	filec := make(chan goFile, 10000)
-	errc := make(chan error, 1)
-
-	go func() {
-		defer func() {
-			close(filec)
-			close(errc)
-		}()
-
-		if workDir != "" && !filepath.IsAbs(workDir) {
-			wd, err := filepath.Abs(workDir)
-			if err != nil {
-				errc <- err
-				return
-			}
+func collectPkgs(e *env, srcDir, workDir string, noVendor bool, sem chan struct{}, st *stats) (map[string]Pkg, error) {
+	w := &walker{env: e, root: srcDir, workDir: workDir, noVendor: noVendor, sem: sem, st: st}
+	found, err := w.walk()
+	if err != nil {
+		return nil, err
+	}
 
-			workDir = wd
+	out := make(map[string]Pkg, len(found))
+	for pkgDir, pkgName := range found {
+		out[pkgDir] = Pkg{
+			Name:       pkgName,
+			ImportPath: filepath.ToSlash(pkgDir[len(srcDir)+len("/"):]),
+			Dir:        pkgDir,
+			Standard:   strings.Contains(pkgDir, e.ctxt.GOROOT),
+			Root:       srcDir,
 		}
+	}
 
-		err := godirwalk.Walk(srcDir, &godirwalk.Options{
-			FollowSymbolicLinks: true,
-			Callback: func(osPathname string, de *godirwalk.Dirent) error {
-				name := de.Name()
-				pathDir := filepath.Dir(osPathname)
-
-				// Symlink not supported by go
-				if de.IsSymlink() {
-					return filepath.SkipDir
-				}
-
-				// Ignore files begin with "_", "." "_test.go" and directory named "testdata"
-				// see: https://golang.org/cmd/go/#hdr-Description_of_package_lists
-
-				if de.IsDir() {
-					if name[0] == '.' || name[0] == '_' || name == testDataDir || name == nodeModulesDir {
-						return filepath.SkipDir
-					}
-
-					if name == "vendor" {
-						if workDir != "" {
-							if !visibleVendor(workDir, pathDir) {
-								return filepath.SkipDir
-							}
-
-							return nil
-						}
-
-						if noVendor {
-							return filepath.SkipDir
-						}
-					}
-
-					return nil
-				}
-
-				if name[0] == '.' || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
-					return nil
-				}
-
-				if pathDir == srcDir {
-					// Cannot put files on $GOPATH/src or $GOROOT/src.
-					return nil
-				}
-
-				filec <- goFile{
-					path: osPathname,
-					dir:  pathDir,
-				}
-				return nil
-			},
-			ErrorCallback: func(s string, err error) godirwalk.ErrorAction {
-				err = pkgerrors.Cause(err)
-				if v, ok := err.(*os.PathError); ok && (os.IsNotExist(v.Err) || os.IsPermission(v.Err)) {
-					return godirwalk.SkipNode
-				}
+	return out, nil
+}
 
-				return godirwalk.Halt
-			},
-		})
+func collectModPkgs(e *env, m mod, sem chan struct{}, st *stats) (map[string]Pkg, error) {
+	w := &walker{env: e, root: m.dir, module: true, sem: sem, st: st}
+	found, err := w.walk()
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			errc <- err
-			return
+	out := make(map[string]Pkg, len(found))
+	for pkgDir, pkgName := range found {
+		importPath := m.path
+		if pkgDir != m.dir {
+			importPath += filepath.ToSlash(pkgDir[len(m.dir):])
 		}
-	}()
-	return filec, errc
-}
 
-func listModFiles(modDir string) (<-chan goFile, <-chan error) {
-	filec := make(chan goFile, 10000)
-	errc := make(chan error, 1)
-
-	go func() {
-		defer func() {
-			close(filec)
-			close(errc)
-		}()
-
-		err := godirwalk.Walk(modDir, &godirwalk.Options{
-			FollowSymbolicLinks: true,
-			Callback: func(osPathname string, de *godirwalk.Dirent) error {
-				name := de.Name()
-				pathDir := filepath.Dir(osPathname)
-
-				// Symlink not supported by go
-				if de.IsSymlink() {
-					return filepath.SkipDir
-				}
-
-				// Ignore files begin with "_", "." "_test.go" and directory named "testdata"
-				// see: https://golang.org/cmd/go/#hdr-Description_of_package_lists
+		out[pkgDir] = Pkg{
+			Name:        pkgName,
+			ImportPath:  importPath,
+			Dir:         pkgDir,
+			Standard:    strings.HasPrefix(pkgDir, e.ctxt.GOROOT),
+			Root:        m.dir,
+			Module:      m.path,
+			Version:     m.version,
+			NotRequired: m.notRequired,
+		}
+	}
 
-				if de.IsDir() {
-					if name[0] == '.' || name[0] == '_' || name == testDataDir || name == nodeModulesDir {
-						return filepath.SkipDir
-					}
+	return out, nil
+}
 
-					return nil
-				}
+// List packages on workDir.
+// workDir is required for module mode. If the workDir is not under module, then it will fallback to GOPATH mode.
+// In module mode, the modules not downloaded are read from their zip on the local (file://) GOPROXY, if any.
+// The vendor directories and the nested modules are not walked as part of a module.
+func List(opts Options) (map[string]Pkg, error) {
+	result, err := ListWithResult(opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Pkgs, nil
+}
 
-				if name[0] == '.' || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
-					return nil
-				}
+// ListWithResult list packages on workDir same as List, along with the details of the listing.
+func ListWithResult(opts Options) (*ListResult, error) {
+	start := time.Now()
+	result := &ListResult{
+		Pkgs: make(map[string]Pkg),
+	}
 
-				filec <- goFile{
-					path: osPathname,
-					dir:  pathDir,
-				}
-				return nil
-			},
-			ErrorCallback: func(s string, err error) godirwalk.ErrorAction {
-				err = pkgerrors.Cause(err)
-				if v, ok := err.(*os.PathError); ok && os.IsNotExist(v.Err) {
-					return godirwalk.SkipNode
-				}
+	e, err := newEnv(opts)
+	if err != nil {
+		return nil, err
+	}
 
-				return godirwalk.Halt
-			},
-		})
+	roots := listRoots(e, opts, result)
+	if err := listPkgs(e, opts, roots, result); err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			errc <- err
-			return
-		}
-	}()
-	return filec, errc
+	result.Duration = time.Since(start)
+	return result, nil
 }
 
-func collectPkgs(srcDir, workDir string, noVendor bool, out map[string]Pkg) error {
-	filec, errc := listFiles(srcDir, workDir, noVendor)
-	for f := range filec {
-		pkgDir := f.dir
-		if _, found := out[pkgDir]; found {
-			// already have this package, skip
-			continue
-		}
-
-		pkgName, err := readPackageName(f.path)
-		if err != nil {
-			// skip unparseable file
-			continue
-		}
-
-		if pkgName == mainPkg {
-			// skip main package
-			continue
-		}
-		out[pkgDir] = Pkg{
-			Name:       pkgName,
-			ImportPath: filepath.ToSlash(pkgDir[len(srcDir)+len("/"):]),
-			Dir:        pkgDir,
-			Standard:   strings.Contains(pkgDir, build.Default.GOROOT),
-		}
+// listPkgs walks the roots for the packages, reading the file listing and imports if requested.
+func listPkgs(e *env, opts Options, roots []root, result *ListResult) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
 	}
 
-	if err := <-errc; err != nil {
+	walkStart := time.Now()
+	if err := collectRoots(roots, concurrency, result); err != nil {
 		return err
 	}
+	result.WalkDuration = time.Since(walkStart)
 
+	if opts.Files || opts.Imports {
+		filesStart := time.Now()
+		if err := readAllPkgFiles(e, result.Pkgs, opts, concurrency); err != nil {
+			return err
+		}
+		result.FilesDuration = time.Since(filesStart)
+	}
 	return nil
 }
 
-func collectModPkgs(m mod, out map[string]Pkg) error {
-	filec, errc := listModFiles(m.dir)
-	for f := range filec {
-		pkgDir := f.dir
-		if _, found := out[pkgDir]; found {
-			// already have this package, skip
-			continue
-		}
+// root is a directory to walk for packages.
+type root struct {
+	dir     string
+	collect func(sem chan struct{}, st *stats) (map[string]Pkg, error)
+}
 
-		pkgName, err := readPackageName(f.path)
-		if err != nil {
-			// skip unparseable file
-			continue
-		}
+// listRoots returns the roots to walk, the mode and the roots are set on result.
+func listRoots(e *env, opts Options, result *ListResult) []root {
+	var roots []root
+	if opts.WorkDir != "" {
+		modListStart := time.Now()
+		mods, err := listMods(opts.WorkDir)
+		result.ModListDuration = time.Since(modListStart)
+		if err == nil {
+			// Module mode
+			result.Mode = ModeModule
+			goroot := filepath.Join(e.ctxt.GOROOT, "src")
+			roots = append(roots, root{
+				dir: goroot,
+				collect: func(sem chan struct{}, st *stats) (map[string]Pkg, error) {
+					return collectPkgs(e, goroot, opts.WorkDir, false, sem, st)
+				},
+			})
+
+			if opts.IncludeModCache {
+				mods = append(mods, notRequiredMods(e, mods)...)
+			}
+			mountProxyZips(e, mods)
 
-		if pkgName == mainPkg {
-			// skip main package
-			continue
-		}
+			for _, m := range mods {
+				if m.dir == "" {
+					// module neither downloaded nor on the local GOPROXY
+					continue
+				}
 
-		// debug := true
-		importPath := m.path
-		if pkgDir != m.dir {
-			importPath += filepath.ToSlash(pkgDir[len(m.dir):])
+				m := m
+				roots = append(roots, root{
+					dir: m.dir,
+					collect: func(sem chan struct{}, st *stats) (map[string]Pkg, error) {
+						return collectModPkgs(e, m, sem, st)
+					},
+				})
+			}
 		}
+	}
 
-		out[pkgDir] = Pkg{
-			Name:       pkgName,
-			ImportPath: importPath,
-			Dir:        pkgDir,
-			Standard:   strings.HasPrefix(pkgDir, build.Default.GOROOT),
+	if result.Mode == "" {
+		// GOPATH mode
+		result.Mode = ModeGOPATH
+		for _, srcDir := range e.ctxt.SrcDirs() {
+			srcDir := srcDir
+			roots = append(roots, root{
+				dir: srcDir,
+				collect: func(sem chan struct{}, st *stats) (map[string]Pkg, error) {
+					return collectPkgs(e, srcDir, opts.WorkDir, opts.NoVendor, sem, st)
+				},
+			})
 		}
 	}
 
-	if err := <-errc; err != nil {
-		return err
+	for _, r := range roots {
+		result.Roots = append(result.Roots, r.dir)
 	}
-
-	return nil
+	return roots
 }
 
-// List packages on workDir.
-// workDir is required for module mode. If the workDir is not under module, then it will fallback to GOPATH mode.
-func List(opts Options) (map[string]Pkg, error) {
-	pkgs := make(map[string]Pkg)
-
-	if opts.WorkDir == "" {
-		// force on GOPATH mode
-		for _, srcDir := range build.Default.SrcDirs() {
-			err := collectPkgs(srcDir, opts.WorkDir, opts.NoVendor, pkgs)
-			if err != nil {
-				return nil, err
-			}
-		}
-		return pkgs, nil
+// collectRoots walks the roots concurrently, the packages found on the earlier root take
+// precedence on the same directory. The roots and their directories share the semaphore,
+// at most concurrency goroutines walk at once.
+func collectRoots(roots []root, concurrency int, result *ListResult) error {
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		pkgs = make([]map[string]Pkg, len(roots))
+		sts  = make([]stats, len(roots))
+		errs = make([]error, len(roots))
+	)
+
+	for i, r := range roots {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, r root) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			pkgs[i], errs[i] = r.collect(sem, &sts[i])
+		}(i, r)
 	}
+	wg.Wait()
 
-	mods, err := listMods(opts.WorkDir)
-	if err != nil {
-		// GOPATH mode
-		for _, srcDir := range build.Default.SrcDirs() {
-			err = collectPkgs(srcDir, opts.WorkDir, opts.NoVendor, pkgs)
-			if err != nil {
-				return nil, err
-			}
+	for i := range roots {
+		if errs[i] != nil {
+			return errs[i]
 		}
-		return pkgs, nil
-	}
 
-	// Module mode
-	if err = collectPkgs(filepath.Join(build.Default.GOROOT, "src"), opts.WorkDir, false, pkgs); err != nil {
-		return nil, err
-	}
-
-	for _, m := range mods {
-		err = collectModPkgs(m, pkgs)
-		if err != nil {
-			return nil, err
+		result.DirsVisited += int(sts[i].dirs)
+		result.FilesRead += int(sts[i].files)
+		for pkgDir, pkg := range pkgs[i] {
+			if _, found := result.Pkgs[pkgDir]; found {
+				// already have this package, skip
+				continue
+			}
+			result.Pkgs[pkgDir] = pkg
 		}
 	}
 
-	return pkgs, nil
+	return nil
 }
 
 type mod struct {
-	path string
-	dir  string
+	path        string
+	version     string
+	dir         string
+	notRequired bool // only in the module cache
 }
 
 func listMods(workDir string) ([]mod, error) {
-	cmdArgs := []string{"list", "-m", "-mod=", "-f={{.Path}};{{.Dir}}", "all"}
+	cmdArgs := []string{"list", "-m", "-mod=", "-f={{.Path}};{{.Dir}};{{.Version}}", "all"}
 	cmd := exec.Command("go", cmdArgs...)
 	cmd.Dir = workDir
 	out, err := cmd.Output()
@@ -385,7 +400,31 @@ func listMods(workDir string) ([]mod, error) {
 	for s.Scan() {
 		line := s.Text()
 		ls := strings.Split(line, ";")
-		mods = append(mods, mod{path: ls[0], dir: ls[1]})
+		m := mod{path: ls[0], dir: ls[1]}
+		if len(ls) > 2 {
+			m.version = ls[2]
+		}
+		mods = append(mods, m)
 	}
 	return mods, nil
 }
+
+// goEnv returns the go environment variable as set on the environment, or else as reported by
+// go env, which reads the values set by go env -w. The go command runs with the GOPATH of the
+// build context, some defaults depending on it, e.g. GOMODCACHE.
+func goEnv(e *env, key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	cmd := exec.Command("go", "env", key)
+	if e.ctxt.GOPATH != "" {
+		cmd.Env = append(os.Environ(), "GOPATH="+e.ctxt.GOPATH)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,203 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import "sort"
+
+// Graph is the import graph of listed packages, keyed by import path.
+// The packages must be retrieved with Options.Imports.
+type Graph struct {
+	imports   map[string][]string
+	importers map[string][]string
+}
+
+// NewGraph builds the import graph of pkgs.
+func NewGraph(pkgs map[string]Pkg) *Graph {
+	g := &Graph{
+		imports:   make(map[string][]string, len(pkgs)),
+		importers: make(map[string][]string),
+	}
+
+	for _, pkg := range pkgs {
+		g.imports[pkg.ImportPath] = pkg.Imports
+	}
+
+	for importPath, imports := range g.imports {
+		for _, imp := range imports {
+			if _, found := g.imports[imp]; !found {
+				// not on the listing
+				continue
+			}
+
+			g.importers[imp] = append(g.importers[imp], importPath)
+		}
+	}
+
+	for _, importers := range g.importers {
+		sort.Strings(importers)
+	}
+
+	return g
+}
+
+// Packages returns the sorted import paths on the graph.
+func (g *Graph) Packages() []string {
+	paths := make([]string, 0, len(g.imports))
+	for importPath := range g.imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Imports returns the direct imports of importPath which are on the graph.
+func (g *Graph) Imports(importPath string) []string {
+	var imports []string
+	for _, imp := range g.imports[importPath] {
+		if _, found := g.imports[imp]; found {
+			imports = append(imports, imp)
+		}
+	}
+	return imports
+}
+
+// Importers returns the packages directly importing importPath.
+func (g *Graph) Importers(importPath string) []string {
+	return g.importers[importPath]
+}
+
+// Deps returns the packages importPath depends on, directly or indirectly.
+func (g *Graph) Deps(importPath string) []string {
+	return g.walk(importPath, g.Imports)
+}
+
+// ReverseDeps returns the packages depending on importPath, directly or indirectly.
+func (g *Graph) ReverseDeps(importPath string) []string {
+	return g.walk(importPath, g.Importers)
+}
+
+func (g *Graph) walk(importPath string, next func(string) []string) []string {
+	seen := map[string]bool{importPath: true}
+	queue := []string{importPath}
+	var paths []string
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, n := range next(p) {
+			if seen[n] {
+				continue
+			}
+
+			seen[n] = true
+			paths = append(paths, n)
+			queue = append(queue, n)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// Cycles returns the import cycles on the graph, one for each set of packages
+// importing each other. A cycle is reported as its shortest path starting and
+// ending on the lowest sorted package in the set, e.g. [a b c a].
+func (g *Graph) Cycles() [][]string {
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  [][]string
+	)
+
+	var strongConnect func(v string)
+	strongConnect = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.Imports(v) {
+			if _, visited := index[w]; !visited {
+				strongConnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] != index[v] {
+			return
+		}
+
+		members := make(map[string]bool)
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			members[w] = true
+			if w == v {
+				break
+			}
+		}
+
+		if cycle := g.shortestCycle(members); cycle != nil {
+			cycles = append(cycles, cycle)
+		}
+	}
+
+	for _, v := range g.Packages() {
+		if _, visited := index[v]; !visited {
+			strongConnect(v)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
+
+// shortestCycle returns the shortest cycle within members, nil if there is none.
+func (g *Graph) shortestCycle(members map[string]bool) []string {
+	var start string
+	for m := range members {
+		if start == "" || m < start {
+			start = m
+		}
+	}
+
+	prev := make(map[string]string)
+	queue := []string{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, n := range g.Imports(p) {
+			if !members[n] {
+				continue
+			}
+
+			if n == start {
+				cycle := []string{start}
+				for c := p; c != start; c = prev[c] {
+					cycle = append(cycle, c)
+				}
+				cycle = append(cycle, start)
+
+				// reverse, path was collected backward
+				for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+
+			if _, seen := prev[n]; seen {
+				continue
+			}
+
+			prev[n] = p
+			queue = append(queue, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Ignore files, listing the directories to skip in gitignore syntax.
+const (
+	gopkgsIgnoreFile = ".gopkgsignore"
+	gitIgnoreFile    = ".gitignore"
+)
+
+// ignoreRule is a pattern of gitignore syntax, matching the directories to skip.
+type ignoreRule struct {
+	base     string   // directory the pattern is relative to, empty for the root walked
+	elems    []string // pattern elements, which may be "**"
+	negate   bool     // pattern starts with "!", the directory is not skipped
+	anchored bool     // pattern contains "/", matched from base rather than on any level
+}
+
+// parseIgnore parses the lines of gitignore syntax, relative to base.
+func parseIgnore(base string, data []byte) []ignoreRule {
+	var rules []ignoreRule
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if rule, ok := parseIgnoreRule(base, s.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		// e.g. \#file or \!file
+		line = line[1:]
+	}
+
+	// only the directories are matched, the trailing slash makes no difference
+	line = strings.TrimSuffix(line, "/")
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return rule, false
+	}
+
+	rule.elems = strings.Split(line, "/")
+	return rule, true
+}
+
+// ignored reports whether the directory is skipped by the rules, the later rules taking precedence.
+// The rules with empty base are relative to root.
+func ignored(rules []ignoreRule, root, dir string) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		r := rules[i]
+		base := r.base
+		if base == "" {
+			base = root
+		}
+
+		rel, err := filepath.Rel(base, dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		if !r.anchored {
+			elems = elems[len(elems)-1:]
+		}
+
+		if matchElems(r.elems, elems) {
+			return !r.negate
+		}
+	}
+	return false
+}
+
+// matchElems matches the path elements against the pattern elements, "**" matching
+// zero or more elements.
+func matchElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(elems) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], elems[0]); err != nil || !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
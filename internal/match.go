@@ -0,0 +1,22 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchPattern returns a function matching import paths against pattern.
+// The pattern is an import path which may contain "..." wildcards, matching any string,
+// see: https://golang.org/cmd/go/#hdr-Package_lists_and_patterns
+func MatchPattern(pattern string) func(importPath string) bool {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\.\.\.`, `.*`, -1)
+
+	// Special case: foo/... matches foo too.
+	if strings.HasSuffix(re, `/.*`) {
+		re = re[:len(re)-len(`/.*`)] + `(/.*)?`
+	}
+
+	reg := regexp.MustCompile(`^` + re + `$`)
+	return reg.MatchString
+}
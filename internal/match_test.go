@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern    string
+		importPath string
+		match      bool
+	}{
+		{pattern: "net/http", importPath: "net/http", match: true},
+		{pattern: "net/http", importPath: "net/http/httptest", match: false},
+		{pattern: "net/...", importPath: "net", match: true},
+		{pattern: "net/...", importPath: "net/http/httptest", match: true},
+		{pattern: "net/...", importPath: "network", match: false},
+		{pattern: "...", importPath: "fmt", match: true},
+		{pattern: "example.com/.../internal", importPath: "example.com/foo/internal", match: true},
+		{pattern: "example.com/.../internal", importPath: "example.com/foo/internal/bar", match: false},
+	}
+
+	for i, c := range cases {
+		if got, want := MatchPattern(c.pattern)(c.importPath), c.match; got != want {
+			t.Error("got:", got, "want:", want, "case:", i)
+		}
+	}
+}
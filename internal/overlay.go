@@ -0,0 +1,205 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// overlayFS is a file system whose files are replaced or added by the overlay contents,
+// the directories of the added files are created as needed.
+type overlayFS struct {
+	base  fs.FS
+	files map[string][]byte          // overlay contents, keyed by name
+	dirs  map[string]map[string]bool // overlay entries, keyed by the name of the directory
+}
+
+// newOverlayFS creates the overlay of base, overlay being the contents keyed by file path.
+func newOverlayFS(base fs.FS, overlay map[string][]byte) (*overlayFS, error) {
+	o := &overlayFS{
+		base:  base,
+		files: make(map[string][]byte, len(overlay)),
+		dirs:  make(map[string]map[string]bool),
+	}
+
+	for filename, content := range overlay {
+		if !filepath.IsAbs(filename) {
+			abs, err := filepath.Abs(filename)
+			if err != nil {
+				return nil, err
+			}
+			filename = abs
+		}
+
+		name := fsName(filename)
+		o.files[name] = content
+		for name != "." {
+			dir := path.Dir(name)
+			if o.dirs[dir] == nil {
+				o.dirs[dir] = make(map[string]bool)
+			}
+			o.dirs[dir][path.Base(name)] = true
+			name = dir
+		}
+	}
+
+	return o, nil
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	if content, ok := o.files[name]; ok {
+		return &overlayFile{
+			Reader: bytes.NewReader(content),
+			info:   overlayInfo{name: path.Base(name), size: int64(len(content))},
+		}, nil
+	}
+
+	f, err := o.base.Open(name)
+	if err != nil && errors.Is(err, fs.ErrNotExist) && o.dirs[name] != nil {
+		return &overlayDir{o: o, name: name, info: overlayInfo{name: path.Base(name), dir: true}}, nil
+	}
+	return f, err
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	if content, ok := o.files[name]; ok {
+		return overlayInfo{name: path.Base(name), size: int64(len(content))}, nil
+	}
+
+	fi, err := fs.Stat(o.base, name)
+	if err != nil && errors.Is(err, fs.ErrNotExist) && o.dirs[name] != nil {
+		return overlayInfo{name: path.Base(name), dir: true}, nil
+	}
+	return fi, err
+}
+
+func (o *overlayFS) ReadFile(name string) ([]byte, error) {
+	if content, ok := o.files[name]; ok {
+		return content, nil
+	}
+	return fs.ReadFile(o.base, name)
+}
+
+// ReadDir reads the entries of the base directory merged with the overlay entries, sorted by file name.
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	des, err := fs.ReadDir(o.base, name)
+	entries := o.dirs[name]
+	if err != nil && (entries == nil || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, err
+	}
+
+	merged := make([]fs.DirEntry, 0, len(des)+len(entries))
+	for _, de := range des {
+		if !entries[de.Name()] {
+			merged = append(merged, de)
+		}
+	}
+
+	for entry := range entries {
+		entryName := path.Join(name, entry)
+		if content, ok := o.files[entryName]; ok {
+			merged = append(merged, overlayInfo{name: entry, size: int64(len(content))})
+			continue
+		}
+
+		if de := findEntry(des, entry); de != nil {
+			// existing directory having overlay files
+			merged = append(merged, de)
+			continue
+		}
+
+		merged = append(merged, overlayInfo{name: entry, dir: true})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+	return merged, nil
+}
+
+func findEntry(des []fs.DirEntry, name string) fs.DirEntry {
+	for _, de := range des {
+		if de.Name() == name {
+			return de
+		}
+	}
+	return nil
+}
+
+// overlayInfo describes an overlay file or a directory created for the overlay files.
+type overlayInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i overlayInfo) Name() string       { return i.name }
+func (i overlayInfo) Size() int64        { return i.size }
+func (i overlayInfo) ModTime() time.Time { return time.Time{} }
+func (i overlayInfo) IsDir() bool        { return i.dir }
+func (i overlayInfo) Sys() interface{}   { return nil }
+func (i overlayInfo) Type() fs.FileMode  { return i.Mode().Type() }
+
+func (i overlayInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func (i overlayInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+type overlayFile struct {
+	*bytes.Reader
+	info overlayInfo
+}
+
+func (f *overlayFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *overlayFile) Close() error               { return nil }
+
+type overlayDir struct {
+	o    *overlayFS
+	name string
+	info overlayInfo
+	des  []fs.DirEntry
+	read bool
+}
+
+func (d *overlayDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *overlayDir) Close() error               { return nil }
+
+func (d *overlayDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		des, err := d.o.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.des, d.read = des, true
+	}
+
+	if n <= 0 {
+		des := d.des
+		d.des = nil
+		return des, nil
+	}
+
+	if len(d.des) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.des) {
+		n = len(d.des)
+	}
+	des := d.des[:n]
+	d.des = d.des[n:]
+	return des, nil
+}
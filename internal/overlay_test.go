@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestListOverlay(t *testing.T) {
+	fsys := fstest.MapFS{
+		"gopath/src/example.com/foo/foo.go":   {Data: []byte("package foo\n")},
+		"gopath/src/example.com/foo/doc.go":   {Data: []byte("package foo\n")},
+		"gopath/src/example.com/empty/README": {Data: []byte("# empty\n")},
+	}
+
+	pkgs, err := List(Options{
+		FS:     fsys,
+		GOROOT: "/goroot",
+		GOPATH: "/gopath",
+		Files:  true,
+		Overlay: map[string][]byte{
+			// renamed package clause
+			"/gopath/src/example.com/foo/doc.go": []byte("package bar\n"),
+			"/gopath/src/example.com/foo/foo.go": []byte("package bar\n\nimport \"fmt\"\n"),
+			// new file on existing directory
+			"/gopath/src/example.com/empty/empty.go": []byte("package empty\n"),
+			// new file on new directory
+			"/gopath/src/example.com/baz/qux/qux.go": []byte("package qux\n"),
+		},
+	})
+	if err != nil {
+		t.Fatal("fail getting packages:", err)
+	}
+
+	want := map[string]Pkg{
+		"/gopath/src/example.com/foo": {
+			Dir:        "/gopath/src/example.com/foo",
+			ImportPath: "example.com/foo",
+			Name:       "bar",
+			Root:       "/gopath/src",
+			GoFiles:    []string{"doc.go", "foo.go"},
+		},
+		"/gopath/src/example.com/empty": {
+			Dir:        "/gopath/src/example.com/empty",
+			ImportPath: "example.com/empty",
+			Name:       "empty",
+			Root:       "/gopath/src",
+			GoFiles:    []string{"empty.go"},
+		},
+		"/gopath/src/example.com/baz/qux": {
+			Dir:        "/gopath/src/example.com/baz/qux",
+			ImportPath: "example.com/baz/qux",
+			Name:       "qux",
+			Root:       "/gopath/src",
+			GoFiles:    []string{"qux.go"},
+		},
+	}
+
+	if !reflect.DeepEqual(pkgs, want) {
+		t.Error("got:", pkgs, "want:", want)
+	}
+}
@@ -0,0 +1,3 @@
+package main
+
+import "example.com/app/internal/util"
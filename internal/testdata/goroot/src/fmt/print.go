@@ -0,0 +1,4 @@
+// Package fmt implements formatted I/O.
+package fmt
+
+import "os"
@@ -0,0 +1,3 @@
+package net
+
+import "C"
@@ -0,0 +1,6 @@
+package http
+
+import (
+	"fmt"
+	"net"
+)
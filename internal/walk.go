@@ -0,0 +1,223 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// walker walks the directories under root, determining the package name of each
+// directory from its files.
+type walker struct {
+	env      *env
+	root     string
+	workDir  string        // vendor directories not visible from workDir are skipped, if specified
+	noVendor bool          // vendor directories are skipped
+	module   bool          // root is a module directory, which can contain a package
+	sem      chan struct{} // limits the goroutines walking, the directory is walked inline when full
+	st       *stats
+
+	mu   sync.Mutex
+	pkgs map[string]string // package name, keyed by directory
+	err  error
+}
+
+// walk returns the package names found under the root, keyed by directory.
+func (w *walker) walk() (map[string]string, error) {
+	if w.workDir != "" && !filepath.IsAbs(w.workDir) {
+		wd, err := filepath.Abs(w.workDir)
+		if err != nil {
+			return nil, err
+		}
+
+		w.workDir = wd
+	}
+
+	w.pkgs = make(map[string]string)
+
+	var wg sync.WaitGroup
+	w.walkDir(w.root, w.env.ignore, &wg)
+	wg.Wait()
+
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.pkgs, nil
+}
+
+// walkDir reads the directory entries once, determines the package name from the
+// candidate files then walks the sub directories not ignored by the rules. The sub
+// directories are walked on new goroutines while the concurrency allows, otherwise
+// on the current one.
+func (w *walker) walkDir(dir string, rules []ignoreRule, wg *sync.WaitGroup) {
+	if w.halted() {
+		return
+	}
+
+	des, err := w.env.readDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return
+		}
+
+		w.halt(err)
+		return
+	}
+
+	atomic.AddInt64(&w.st.dirs, 1)
+
+	rules = w.readIgnore(dir, des, rules)
+
+	var candidates, subDirs []string
+	for _, de := range des {
+		name := de.Name()
+
+		// Symlink not supported by go
+		if de.Type()&fs.ModeSymlink != 0 {
+			continue
+		}
+
+		// Ignore files begin with "_", "." "_test.go" and directory named "testdata"
+		// see: https://golang.org/cmd/go/#hdr-Description_of_package_lists
+
+		if w.module && dir != w.root && name == goModFile && !de.IsDir() {
+			// nested module is not part of the module
+			return
+		}
+
+		if de.IsDir() {
+			if w.skipDir(dir, name) {
+				continue
+			}
+
+			subDir := filepath.Join(dir, name)
+			if ignored(rules, w.root, subDir) {
+				continue
+			}
+
+			subDirs = append(subDirs, subDir)
+			continue
+		}
+
+		if name[0] == '.' || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+
+	// Cannot put files on $GOPATH/src or $GOROOT/src.
+	if dir != w.root || w.module {
+		if pkgName, ok := w.readPkgName(candidates); ok {
+			w.mu.Lock()
+			w.pkgs[dir] = pkgName
+			w.mu.Unlock()
+		}
+	}
+
+	for _, subDir := range subDirs {
+		select {
+		case w.sem <- struct{}{}:
+			wg.Add(1)
+			go func(subDir string) {
+				defer func() {
+					<-w.sem
+					wg.Done()
+				}()
+
+				w.walkDir(subDir, rules, wg)
+			}(subDir)
+		default:
+			w.walkDir(subDir, rules, wg)
+		}
+	}
+}
+
+// readIgnore returns the rules along with the ones of the ignore files on the directory.
+// The rules are not modified, they are shared by the sibling directories.
+func (w *walker) readIgnore(dir string, des []fs.DirEntry, rules []ignoreRule) []ignoreRule {
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || (name != gopkgsIgnoreFile && (name != gitIgnoreFile || !w.env.gitIgnore)) {
+			continue
+		}
+
+		if name == gopkgsIgnoreFile && dir == w.env.ignoreDir {
+			// already on the rules of env
+			continue
+		}
+
+		data, err := w.env.readFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules[:len(rules):len(rules)], parseIgnore(dir, data)...)
+	}
+	return rules
+}
+
+func (w *walker) skipDir(parent, name string) bool {
+	if name[0] == '.' || name[0] == '_' || name == testDataDir || name == nodeModulesDir {
+		return true
+	}
+
+	if name != "vendor" {
+		return false
+	}
+
+	if w.module {
+		// vendored packages are not part of the module, nor importable by their directory
+		return true
+	}
+
+	if w.workDir != "" {
+		return !visibleVendor(w.workDir, parent)
+	}
+
+	return w.noVendor
+}
+
+// readPkgName returns the package name declared by the first parseable file, other than main
+// package. The directory is a main package if all the files declare it and the main packages
+// are listed, the main files being e.g. generators excluded by build constraints otherwise.
+func (w *walker) readPkgName(files []string) (string, bool) {
+	isMain := false
+	for _, f := range files {
+		atomic.AddInt64(&w.st.files, 1)
+		pkgName, err := readPackageName(w.env, f)
+		if err != nil {
+			// skip unparseable file
+			continue
+		}
+
+		if pkgName == mainPkg {
+			isMain = true
+			continue
+		}
+
+		return pkgName, true
+	}
+
+	if isMain && w.env.includeMain {
+		return mainPkg, true
+	}
+	return "", false
+}
+
+func (w *walker) halt(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+func (w *walker) halted() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
@@ -0,0 +1,61 @@
+package gopkgs // import "github.com/uudashr/gopkgs/v2"
+
+import (
+	"github.com/uudashr/gopkgs/v2/internal"
+)
+
+// Module is a module version, either on the build list or downloaded in the module cache.
+type Module internal.Module
+
+// ListModules returns the modules on the build list of workDir, as reported by "go list -m all".
+func ListModules(opts Options) ([]Module, error) {
+	mods, err := internal.ListModules(internal.Options(opts))
+	if err != nil {
+		return nil, err
+	}
+	return toModules(mods), nil
+}
+
+// ListModCache returns the module versions downloaded in the module cache, sorted by path
+// then version. The module cache is $GOMODCACHE, or pkg/mod of the first GOPATH entry.
+func ListModCache(opts Options) ([]Module, error) {
+	mods, err := internal.ListModCache(internal.Options(opts))
+	if err != nil {
+		return nil, err
+	}
+	return toModules(mods), nil
+}
+
+// ListProxyModules returns the module versions in the local GOPROXY directory, the first
+// file:// entry of $GOPROXY, sorted by path then version.
+func ListProxyModules(opts Options) ([]Module, error) {
+	mods, err := internal.ListProxyModules(internal.Options(opts))
+	if err != nil {
+		return nil, err
+	}
+	return toModules(mods), nil
+}
+
+// ListModule returns the packages of the module, keyed by directory. The module zip is read
+// instead if there is no module directory, the package directories being named under the
+// zip file path.
+func ListModule(opts Options, m Module) (map[string]Pkg, error) {
+	result, err := internal.ListModule(internal.Options(opts), internal.Module(m))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]Pkg, len(result))
+	for key, pkg := range result {
+		pkgs[key] = Pkg(pkg)
+	}
+	return pkgs, nil
+}
+
+func toModules(mods []internal.Module) []Module {
+	out := make([]Module, len(mods))
+	for i, m := range mods {
+		out[i] = Module(m)
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestPkgsNamed(t *testing.T) {
+	ix := testIndex(
+		gopkgs.Pkg{ImportPath: "github.com/other/errors", Name: "errors", NotRequired: true},
+		gopkgs.Pkg{ImportPath: "github.com/pkg/errors", Name: "errors"},
+		gopkgs.Pkg{ImportPath: "errors", Name: "errors", Standard: true},
+		gopkgs.Pkg{ImportPath: "example.com/m/internal/errors", Name: "errors"},
+		gopkgs.Pkg{ImportPath: "example.com/m/vendor/github.com/pkg/errors", Name: "errors"},
+		gopkgs.Pkg{ImportPath: "vendor/golang.org/x/errors", Name: "errors", Standard: true},
+		gopkgs.Pkg{ImportPath: "internal/errors", Name: "errors", Standard: true},
+		gopkgs.Pkg{ImportPath: "example.com/x/internal/errors", Name: "errors"},
+		gopkgs.Pkg{ImportPath: "example.com/m/errs", Name: "errs"},
+	)
+
+	var got []string
+	for _, pkg := range pkgsNamed(ix, "errors", "example.com/m", "example.com/m/cmd/app") {
+		got = append(got, pkg.ImportPath)
+	}
+
+	want := []string{"errors", "example.com/m/internal/errors", "github.com/pkg/errors", "github.com/other/errors"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
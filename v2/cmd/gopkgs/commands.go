@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// command is a gopkgs sub command, invoked as "gopkgs <name> [flags] [args]".
+type command struct {
+	usage  string // usage line, without the program name
+	short  string // short description
+	run    func(args []string) error
+	hidden bool // not listed on the usage, e.g. used by the completion scripts
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"driver": {
+			usage: "driver [patterns]",
+			short: "answer the listing queries of golang.org/x/tools/go/packages (GOPACKAGESDRIVER)",
+			run:   runDriver,
+		},
+		"graph": {
+			usage: "graph [-type dot|mermaid] [-imports] [flags] [patterns]",
+			short: "render the package tree or the import graph as Graphviz DOT or Mermaid",
+			run:   runGraph,
+		},
+		"lsp": {
+			usage: "lsp [-interval duration] [flags]",
+			short: "serve the package listing and search as JSON-RPC 2.0 over stdio",
+			run:   runLSP,
+		},
+		"http": {
+			usage: "http [-addr host:port] [flags]",
+			short: "serve the package listing and search as a JSON HTTP API",
+			run:   runHTTP,
+		},
+		"modules": {
+			usage: "modules [-cached|-proxy] [-pkgs] [-format template] [flags] [module[@version]...]",
+			short: "list the modules on the build list, or the module versions in the module cache or local GOPROXY",
+			run:   runModules,
+		},
+		"pick": {
+			usage: "pick [-file file.go] [-query query] [flags]",
+			short: "pick a package interactively, printing its import path or importing it into a file",
+			run:   runPick,
+		},
+		"add-import": {
+			usage: "add-import -file file.go [-i] [flags] <importpath|name>...",
+			short: "add the imports of the import paths or package names to a Go file",
+			run:   runAddImport,
+		},
+		"fix-imports": {
+			usage: "fix-imports [-w] [-exported] [flags] <file.go>",
+			short: "resolve the package qualifiers not imported on a Go file, printing or adding the imports",
+			run:   runFixImports,
+		},
+		"importers": {
+			usage: "importers [-r] [flags] <importpath>",
+			short: "list the packages importing the package",
+			run:   runImporters,
+		},
+		"check": {
+			usage: "check [-rules file] [flags]",
+			short: "check the packages under workDir for import cycles and forbidden imports",
+			run:   runCheck,
+		},
+		"completion": {
+			usage: "completion bash|zsh|fish",
+			short: "print the shell completion script of the go and gopkgs package arguments",
+			run:   runCompletion,
+		},
+		"__complete": {
+			usage:  "__complete [flags] [prefix]",
+			short:  "print the import paths starting with the prefix",
+			run:    runComplete,
+			hidden: true,
+		},
+		"config": {
+			usage: "config [flags]",
+			short: "print the effective flags, read from the flags, environment and config files",
+			run:   runConfig,
+		},
+		"deps": {
+			usage: "deps [-direct] [flags] <importpath>",
+			short: "list the packages the package depends on",
+			run:   runDeps,
+		},
+	}
+}
+
+func printCommands() {
+	names := commandNames()
+	if len(names) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].short)
+	}
+}
+
+// commandNames returns the sorted names of the commands, other than the hidden ones.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exitCode is an error reporting the command wants to exit with the code, the details
+// already printed by the command.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+// newFlagSet creates the flag set of the named command.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// listFlags are the flags controlling how the packages are listed.
+type listFlags struct {
+	workDir     *string
+	noVendor    *bool
+	modCache    *bool
+	exclude     *string
+	gitIgnore   *bool
+	concurrency *int
+	overlay     *string
+}
+
+func addListFlags(fs *flag.FlagSet) listFlags {
+	return listFlags{
+		workDir:     fs.String("workDir", "", "importable packages only for workDir"),
+		noVendor:    fs.Bool("no-vendor", false, "exclude vendor dependencies except under workDir (if specified)"),
+		exclude:     fs.String("exclude", "", excludeUsage),
+		gitIgnore:   fs.Bool("gitignore", false, "skip the directories ignored by the .gitignore files"),
+		modCache:    fs.Bool("modcache", false, "also list the latest cached version of the modules not required, only in module mode"),
+		concurrency: fs.Int("concurrency", 0, "number of directories walked concurrently, 0 means the number of CPUs"),
+		overlay:     fs.String("overlay", "", "JSON file replacing the file contents, same as go build -overlay"),
+	}
+}
+
+func (f listFlags) options() (gopkgs.Options, error) {
+	overlay, err := readOverlay(*f.overlay)
+	if err != nil {
+		return gopkgs.Options{}, err
+	}
+
+	return gopkgs.Options{
+		WorkDir:         *f.workDir,
+		NoVendor:        *f.noVendor,
+		IncludeModCache: *f.modCache,
+		Exclude:         splitList(*f.exclude),
+		GitIgnore:       *f.gitIgnore,
+		Concurrency:     *f.concurrency,
+		Overlay:         overlay,
+	}, nil
+}
+
+const excludeUsage = "comma separated directories to skip, in gitignore syntax relative to each root, e.g. bazel-out,/third_party/js"
+
+// splitList splits the comma separated list, ignoring the empty elements.
+func splitList(s string) []string {
+	var list []string
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var driverUsageInfo = `
+Implements the driver protocol of golang.org/x/tools/go/packages, reading the request
+as JSON from stdin and writing the response as JSON to stdout. Only the listing queries
+(NeedName and NeedFiles) without tests and build flags, GOFLAGS or CGO_ENABLED included,
+are answered, other queries are reported as not handled so go/packages fallback to go list.
+So are the patterns matching a main package or no package at all. No pattern means ".".
+
+GOPACKAGESDRIVER must name an executable, use a wrapper script such as:
+	#!/bin/sh
+	exec gopkgs driver "$@"
+`
+
+// Load modes of golang.org/x/tools/go/packages.
+const (
+	needName  = 1 << 0
+	needFiles = 1 << 1
+)
+
+// driverRequest is the request of go/packages to the driver.
+type driverRequest struct {
+	Mode       int               `json:"mode"`
+	Env        []string          `json:"env"`
+	BuildFlags []string          `json:"build_flags"`
+	Tests      bool              `json:"tests"`
+	Overlay    map[string][]byte `json:"overlay"`
+}
+
+// driverResponse is the response of the driver to go/packages.
+type driverResponse struct {
+	NotHandled bool
+	Compiler   string
+	Arch       string
+	Roots      []string         `json:",omitempty"`
+	Packages   []*driverPackage `json:",omitempty"`
+	GoVersion  int              `json:",omitempty"`
+}
+
+// driverPackage is the package as described by go/packages.
+type driverPackage struct {
+	ID           string
+	Name         string   `json:",omitempty"`
+	PkgPath      string   `json:",omitempty"`
+	GoFiles      []string `json:",omitempty"`
+	IgnoredFiles []string `json:",omitempty"`
+}
+
+func runDriver(args []string) error {
+	fs := newFlagSet("driver")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["driver"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, driverUsageInfo)
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var req driverRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		return fmt.Errorf("fail decoding driver request: %v", err)
+	}
+
+	resp, err := driverList(req, fs.Args())
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(resp)
+}
+
+func driverList(req driverRequest, patterns []string) (*driverResponse, error) {
+	resp := &driverResponse{
+		Compiler:  runtime.Compiler,
+		Arch:      runtime.GOARCH,
+		GoVersion: goMinorVersion(),
+	}
+
+	if req.Mode&^(needName|needFiles) != 0 || req.Tests || len(req.BuildFlags) > 0 {
+		resp.NotHandled = true
+		return resp, nil
+	}
+
+	opts := gopkgs.Options{
+		Files:       req.Mode&needFiles != 0,
+		IncludeMain: true,
+		Overlay:     req.Overlay,
+	}
+
+	for _, kv := range req.Env {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			continue
+		}
+
+		key, value := kv[:i], kv[i+1:]
+		switch key {
+		case "GOFLAGS", "CGO_ENABLED":
+			// like the build flags, they change the files matched, e.g. -tags
+			if value != "" {
+				resp.NotHandled = true
+				return resp, nil
+			}
+		case "GOOS":
+			opts.GOOS = value
+		case "GOARCH":
+			opts.GOARCH = value
+			resp.Arch = value
+		case "GOROOT":
+			opts.GOROOT = value
+		case "GOPATH":
+			opts.GOPATH = value
+		}
+
+		// for the go command run by the listing, e.g. GOPROXY
+		os.Setenv(key, value)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.WorkDir = wd
+	result, err := gopkgs.ListWithResult(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		// the package of the current directory, like go list
+		patterns = []string{"."}
+	}
+
+	matchers, err := driverMatchers(wd, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]bool, len(matchers))
+	var pkgs []gopkgs.Pkg
+	for _, pkg := range result.Pkgs {
+		found := false
+		for i, match := range matchers {
+			if match(pkg) {
+				matched[i], found = true, true
+			}
+		}
+
+		if !found {
+			continue
+		}
+
+		if pkg.Name == "main" {
+			// main packages are not handled, e.g. their files are not classified as go list does
+			resp.NotHandled = true
+			return resp, nil
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	for _, m := range matched {
+		if !m {
+			// let go list report the pattern
+			resp.NotHandled = true
+			return resp, nil
+		}
+	}
+
+	for _, pkg := range pkgs {
+		dp := &driverPackage{
+			ID:      pkg.ImportPath,
+			Name:    pkg.Name,
+			PkgPath: pkg.ImportPath,
+		}
+
+		for _, names := range [][]string{pkg.GoFiles, pkg.CgoFiles} {
+			for _, name := range names {
+				dp.GoFiles = append(dp.GoFiles, filepath.Join(pkg.Dir, name))
+			}
+		}
+		sort.Strings(dp.GoFiles)
+
+		for _, name := range pkg.IgnoredFiles {
+			dp.IgnoredFiles = append(dp.IgnoredFiles, filepath.Join(pkg.Dir, name))
+		}
+
+		resp.Roots = append(resp.Roots, dp.ID)
+		resp.Packages = append(resp.Packages, dp)
+	}
+
+	sort.Strings(resp.Roots)
+	sort.Slice(resp.Packages, func(i, j int) bool {
+		return resp.Packages[i].ID < resp.Packages[j].ID
+	})
+	return resp, nil
+}
+
+// driverMatchers returns the functions matching the packages against each driver pattern, which can be:
+//
+//	an import path pattern, e.g. net/..., all and std
+//	a directory pattern relative to wd, e.g. ./...
+//	pattern=<pattern> and file=<file> queries
+func driverMatchers(wd string, patterns []string) ([]func(gopkgs.Pkg) bool, error) {
+	var matchers []func(gopkgs.Pkg) bool
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "pattern=")
+		switch {
+		case strings.HasPrefix(pattern, "file="):
+			dir, err := filepath.Abs(filepath.Dir(strings.TrimPrefix(pattern, "file=")))
+			if err != nil {
+				return nil, err
+			}
+
+			matchers = append(matchers, func(pkg gopkgs.Pkg) bool {
+				return pkg.Dir == dir
+			})
+		case pattern == "all" || pattern == "...":
+			matchers = append(matchers, func(gopkgs.Pkg) bool {
+				return true
+			})
+		case pattern == "std":
+			matchers = append(matchers, func(pkg gopkgs.Pkg) bool {
+				// same as go list, std excludes the commands of the go distribution
+				return pkg.Standard && pkg.ImportPath != "cmd" && !strings.HasPrefix(pkg.ImportPath, "cmd/")
+			})
+		case build.IsLocalImport(pattern) || filepath.IsAbs(pattern):
+			dir := pattern
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(wd, dir)
+			}
+
+			match := gopkgs.MatchPattern(filepath.ToSlash(filepath.Clean(dir)))
+			matchers = append(matchers, func(pkg gopkgs.Pkg) bool {
+				return match(filepath.ToSlash(pkg.Dir))
+			})
+		default:
+			match := gopkgs.MatchPattern(pattern)
+			matchers = append(matchers, func(pkg gopkgs.Pkg) bool {
+				return match(pkg.ImportPath)
+			})
+		}
+	}
+
+	return matchers, nil
+}
+
+// goMinorVersion returns the minor version of the go release, e.g. 16 for go1.16.
+func goMinorVersion() int {
+	v := strings.TrimPrefix(runtime.Version(), "go1.")
+	if i := strings.IndexAny(v, ".rb"); i >= 0 {
+		v = v[:i]
+	}
+
+	minor, err := strconv.Atoi(v)
+	if err != nil {
+		// development version
+		return 0
+	}
+	return minor
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDriverList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{
+		"m.go":               "package m\n",
+		"cmd/app/main.go":    "package main\n\nfunc main() {}\n",
+		"lib/lib.go":         "package lib\n",
+		"lib/lib_linux.go":   "package lib\n",
+		"lib/lib_arm64.go":   "package lib\n",
+		"lib/lib_amd64.go":   "package lib\n",
+		"lib/lib_windows.go": "package lib\n",
+	})
+	defer chdir(t, dir)()
+	for _, key := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED"} {
+		defer setenv(t, key, os.Getenv(key))()
+	}
+
+	lib := func(names ...string) []string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, "lib", name)
+		}
+		return names
+	}
+
+	cases := []struct {
+		name           string
+		req            driverRequest
+		patterns       []string
+		wantNotHandled bool
+		wantArch       string
+		wantPkg        *driverPackage
+	}{
+		{
+			name:     "files of the target",
+			req:      driverRequest{Mode: needName | needFiles, Env: []string{"GOOS=windows", "GOARCH=arm64"}},
+			patterns: []string{"./lib"},
+			wantArch: "arm64",
+			wantPkg: &driverPackage{
+				ID:           "example.com/m/lib",
+				Name:         "lib",
+				PkgPath:      "example.com/m/lib",
+				GoFiles:      lib("lib.go", "lib_arm64.go", "lib_windows.go"),
+				IgnoredFiles: lib("lib_amd64.go", "lib_linux.go"),
+			},
+		},
+		{
+			name:           "build flags",
+			req:            driverRequest{Mode: needName, BuildFlags: []string{"-tags=integration"}},
+			patterns:       []string{"./lib"},
+			wantNotHandled: true,
+		},
+		{
+			name:    "no pattern",
+			req:     driverRequest{Mode: needName},
+			wantPkg: &driverPackage{ID: "example.com/m", Name: "m", PkgPath: "example.com/m"},
+		},
+		{
+			name:           "GOFLAGS",
+			req:            driverRequest{Mode: needName, Env: []string{"GOFLAGS=-tags=integration"}},
+			patterns:       []string{"./lib"},
+			wantNotHandled: true,
+		},
+		{
+			name:           "CGO_ENABLED",
+			req:            driverRequest{Mode: needName, Env: []string{"CGO_ENABLED=0"}},
+			patterns:       []string{"./lib"},
+			wantNotHandled: true,
+		},
+		{
+			name:           "main package",
+			req:            driverRequest{Mode: needName | needFiles},
+			patterns:       []string{"./cmd/app"},
+			wantNotHandled: true,
+		},
+		{
+			name:           "main package on wildcard",
+			req:            driverRequest{Mode: needName},
+			patterns:       []string{"example.com/m/..."},
+			wantNotHandled: true,
+		},
+		{
+			name:           "no package",
+			req:            driverRequest{Mode: needName},
+			patterns:       []string{"./lib", "./nosuch"},
+			wantNotHandled: true,
+		},
+		{
+			name:           "tests",
+			req:            driverRequest{Mode: needName, Tests: true},
+			patterns:       []string{"./lib"},
+			wantNotHandled: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := driverList(c.req, c.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.NotHandled != c.wantNotHandled {
+				t.Fatalf("got NotHandled: %t, want: %t", resp.NotHandled, c.wantNotHandled)
+			}
+
+			if c.wantArch != "" && resp.Arch != c.wantArch {
+				t.Errorf("got Arch: %s, want: %s", resp.Arch, c.wantArch)
+			}
+
+			if c.wantPkg == nil {
+				return
+			}
+
+			if len(resp.Packages) != 1 {
+				t.Fatalf("got %d packages, want: 1", len(resp.Packages))
+			}
+
+			if !reflect.DeepEqual(resp.Packages[0], c.wantPkg) {
+				t.Errorf("got: %+v, want: %+v", resp.Packages[0], c.wantPkg)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var fixImportsUsageInfo = `
+Finds the package qualifiers used on the Go file but not imported, e.g. yaml of yaml.Marshal,
+and resolves them to the packages of that name importable from the file, the same way as
+add-import. The imports are printed, or with -w added to the file. With -exported, only
+the packages declaring the exported identifiers selected on the file are considered.
+Exits with status 1 if a qualifier is left unresolved.
+`
+
+func runFixImports(args []string) error {
+	fs := newFlagSet("fix-imports")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["fix-imports"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, fixImportsUsageInfo)
+	}
+	lf := addListFlags(fs)
+	write := fs.Bool("w", false, "add the imports to the file instead of printing them")
+	exported := fs.Bool("exported", false, "only consider the packages declaring the selected identifiers")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitCode(2)
+	}
+	file := fs.Arg(0)
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		opts.WorkDir = dir
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	ix := newIndex(opts)
+	if err := ix.ensure(); err != nil {
+		return err
+	}
+
+	quals := unresolvedQualifiers(ix, f, dir, filepath.Base(file))
+	names := make([]string, 0, len(quals))
+	for name := range quals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	modPath, pkgPath := fileModulePath(dir)
+	unresolved := false
+	for _, name := range names {
+		var pkg gopkgs.Pkg
+		found := false
+		for _, cand := range pkgsNamed(ix, name, modPath, pkgPath) {
+			if !*exported || declares(cand, quals[name]) {
+				pkg, found = cand, true
+				break
+			}
+		}
+
+		if !found {
+			fmt.Fprintf(os.Stderr, "%s: no package named %s declaring %s\n", file, name, strings.Join(quals[name], ", "))
+			unresolved = true
+			continue
+		}
+
+		importName := importName(pkg.ImportPath, pkg.Name)
+		if !*write {
+			fmt.Println(strings.TrimSpace(importName + " " + strconv.Quote(pkg.ImportPath)))
+			continue
+		}
+
+		if _, err := addImportFile(file, importName, pkg.ImportPath); err != nil {
+			return err
+		}
+	}
+
+	if unresolved {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// unresolvedQualifiers returns the identifiers selected on the undeclared and not imported
+// qualifiers of the file, keyed by qualifier. The file is named base on dir, the other files
+// of the package being read for the package level declarations, the test files only for a
+// test file.
+func unresolvedQualifiers(ix *index, f *ast.File, dir, base string) map[string][]string {
+	declared := packageDecls(dir, f.Name.Name, base, strings.HasSuffix(base, "_test.go"), false)
+	for _, spec := range f.Imports {
+		if spec.Name != nil {
+			declared[spec.Name.Name] = true
+			continue
+		}
+
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		if pkg, found := ix.get(importPath); found {
+			declared[pkg.Name] = true
+		} else {
+			declared[assumedName(importPath)] = true
+		}
+	}
+
+	quals := make(map[string][]string)
+	seen := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		id, ok := sel.X.(*ast.Ident)
+		if !ok || id.Obj != nil || declared[id.Name] || id.Name == "_" {
+			return true
+		}
+
+		if key := id.Name + "." + sel.Sel.Name; !seen[key] {
+			seen[key] = true
+			quals[id.Name] = append(quals[id.Name], sel.Sel.Name)
+		}
+		return true
+	})
+	return quals
+}
+
+// declares reports whether the package declares the exported identifiers.
+func declares(pkg gopkgs.Pkg, idents []string) bool {
+	decls := packageDecls(pkg.Dir, pkg.Name, "", false, true)
+	for _, ident := range idents {
+		if !decls[ident] {
+			return false
+		}
+	}
+	return true
+}
+
+// packageDecls returns the package level identifiers declared on the files of the package
+// on dir, other than the file named exclude. The test files are read only with tests, and
+// with exported only the exported identifiers are returned.
+func packageDecls(dir, pkgName, exclude string, tests, exported bool) map[string]bool {
+	decls := make(map[string]bool)
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return decls
+	}
+
+	fset := token.NewFileSet()
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || name == exclude || !strings.HasSuffix(name, ".go") || (!tests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil || f.Name.Name != pkgName {
+			continue
+		}
+
+		add := func(id *ast.Ident) {
+			if !exported || id.IsExported() {
+				decls[id.Name] = true
+			}
+		}
+
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					add(d.Name)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						for _, id := range spec.Names {
+							add(id)
+						}
+					case *ast.TypeSpec:
+						add(spec.Name)
+					}
+				}
+			}
+		}
+	}
+	return decls
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestUnresolvedQualifiers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"decl.go":         "package p\n\nvar conf struct{ Name string }\n\nfunc helper() {}\n",
+		"decl_test.go":    "package p\n\nvar fixture struct{ Name string }\n",
+		"other.go":        "package other\n\nvar stray struct{ Name string }\n",
+		"ext_test.go":     "package p_test\n\nvar extFixture struct{ Name string }\n",
+		"broken_test.go":  "package p\n\nvar (",
+		"generated.go.in": "package p\n\nvar yaml struct{}\n",
+	})
+
+	ix := testIndex(gopkgs.Pkg{ImportPath: "gopkg.in/yaml.v3", Name: "yaml"})
+
+	cases := []struct {
+		name string
+		base string
+		src  string
+		want map[string][]string
+	}{
+		{
+			name: "qualifiers",
+			base: "p.go",
+			src: `package p
+
+import (
+	"fmt"
+	str "strings"
+	"gopkg.in/yaml.v3"
+	"example.com/go-lib/v2"
+)
+
+func f() {
+	local := conf
+	fmt.Println(str.ToUpper(local.Name), conf.Name, yaml.Marshal, lib.X)
+	json.Marshal(json.Unmarshal, json.Marshal)
+	_ = sql.Open
+	_ = stray.Name
+}
+`,
+			want: map[string][]string{"json": {"Marshal", "Unmarshal"}, "sql": {"Open"}, "stray": {"Name"}},
+		},
+		{
+			name: "test declarations out of test",
+			base: "p.go",
+			src:  "package p\n\nvar _ = fixture.Name\n",
+			want: map[string][]string{"fixture": {"Name"}},
+		},
+		{
+			name: "test declarations on test",
+			base: "p_test.go",
+			src:  "package p\n\nvar _ = fixture.Name + conf.Name + extFixture.Name\n",
+			want: map[string][]string{"extFixture": {"Name"}},
+		},
+		{
+			name: "external test",
+			base: "p_ext_test.go",
+			src:  "package p_test\n\nvar _ = extFixture.Name + conf.Name\n",
+			want: map[string][]string{"conf": {"Name"}},
+		},
+		{
+			name: "excluded file",
+			base: "decl.go",
+			src:  "package p\n\nvar _ = conf.Name\n",
+			want: map[string][]string{"conf": {"Name"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, filepath.Join(dir, c.base), c.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := unresolvedQualifiers(ix, f, dir, c.base)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %v, want: %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDeclares(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"yaml.go":      "package yaml\n\nfunc Marshal() {}\n\ntype Node struct{}\n\nfunc (Node) Encode() {}\n\nvar unexported int\n",
+		"yaml_test.go": "package yaml\n\nvar Fixture int\n",
+	})
+	pkg := gopkgs.Pkg{Dir: dir, Name: "yaml"}
+
+	cases := []struct {
+		idents []string
+		want   bool
+	}{
+		{idents: []string{"Marshal", "Node"}, want: true},
+		{idents: []string{"Encode"}, want: false},
+		{idents: []string{"unexported"}, want: false},
+		{idents: []string{"Fixture"}, want: false},
+	}
+
+	for _, c := range cases {
+		if got := declares(pkg, c.idents); got != c.want {
+			t.Errorf("declares(%s) got: %t, want: %t", strings.Join(c.idents, ", "), got, c.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// diagram is the node and edge list to render, nodes are identified by import path.
+type diagram struct {
+	nodes  []string
+	labels map[string]string
+	edges  [][2]string
+}
+
+func runGraph(args []string) error {
+	fs := newFlagSet("graph")
+	lf := addListFlags(fs)
+	graphType := fs.String("type", "dot", "output type, dot or mermaid")
+	imports := fs.Bool("imports", false, "render the import graph instead of the package tree")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var render func(io.Writer, diagram) error
+	switch *graphType {
+	case "dot":
+		render = renderDOT
+	case "mermaid":
+		render = renderMermaid
+	default:
+		return fmt.Errorf("unknown graph type %q, expect dot or mermaid", *graphType)
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	opts.Imports = *imports
+	pkgs, err := gopkgs.List(opts)
+	if err != nil {
+		return err
+	}
+
+	matched := filterPkgs(pkgs, fs.Args())
+
+	var d diagram
+	if *imports {
+		d = importDiagram(matched)
+	} else {
+		d = treeDiagram(matched)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err = render(w, d); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// filterPkgs returns the packages matching any of the patterns, all packages if there is no pattern.
+func filterPkgs(pkgs map[string]gopkgs.Pkg, patterns []string) map[string]gopkgs.Pkg {
+	if len(patterns) == 0 {
+		return pkgs
+	}
+
+	matchers := make([]func(string) bool, len(patterns))
+	for i, pattern := range patterns {
+		matchers[i] = gopkgs.MatchPattern(pattern)
+	}
+
+	matched := make(map[string]gopkgs.Pkg)
+	for dir, pkg := range pkgs {
+		for _, match := range matchers {
+			if match(pkg.ImportPath) {
+				matched[dir] = pkg
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// treeDiagram renders the import path hierarchy, each path element being a node.
+func treeDiagram(pkgs map[string]gopkgs.Pkg) diagram {
+	d := diagram{labels: make(map[string]string)}
+	for _, pkg := range pkgs {
+		p := pkg.ImportPath
+		for {
+			if _, found := d.labels[p]; found {
+				break
+			}
+
+			d.labels[p] = path.Base(p)
+			d.nodes = append(d.nodes, p)
+
+			parent := path.Dir(p)
+			if parent == "." {
+				break
+			}
+
+			d.edges = append(d.edges, [2]string{parent, p})
+			p = parent
+		}
+	}
+
+	d.sort()
+	return d
+}
+
+// importDiagram renders the imports between the packages.
+func importDiagram(pkgs map[string]gopkgs.Pkg) diagram {
+	g := gopkgs.NewGraph(pkgs)
+	d := diagram{labels: make(map[string]string)}
+	for _, p := range g.Packages() {
+		d.nodes = append(d.nodes, p)
+		d.labels[p] = p
+		for _, imp := range g.Imports(p) {
+			d.edges = append(d.edges, [2]string{p, imp})
+		}
+	}
+
+	d.sort()
+	return d
+}
+
+func (d *diagram) sort() {
+	sort.Strings(d.nodes)
+	sort.Slice(d.edges, func(i, j int) bool {
+		if d.edges[i][0] != d.edges[j][0] {
+			return d.edges[i][0] < d.edges[j][0]
+		}
+		return d.edges[i][1] < d.edges[j][1]
+	})
+}
+
+func renderDOT(w io.Writer, d diagram) error {
+	fmt.Fprintln(w, "digraph gopkgs {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, n := range d.nodes {
+		fmt.Fprintf(w, "\t%s [label=%s];\n", strconv.Quote(n), strconv.Quote(d.labels[n]))
+	}
+
+	for _, e := range d.edges {
+		fmt.Fprintf(w, "\t%s -> %s;\n", strconv.Quote(e[0]), strconv.Quote(e[1]))
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func renderMermaid(w io.Writer, d diagram) error {
+	// mermaid node ids are restricted, use the index of the node instead
+	ids := make(map[string]string, len(d.nodes))
+	fmt.Fprintln(w, "graph LR")
+	for i, n := range d.nodes {
+		ids[n] = "n" + strconv.Itoa(i)
+		fmt.Fprintf(w, "\t%s[\"%s\"]\n", ids[n], mermaidEscaper.Replace(d.labels[n]))
+	}
+
+	for _, e := range d.edges {
+		if _, err := fmt.Fprintf(w, "\t%s --> %s\n", ids[e[0]], ids[e[1]]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
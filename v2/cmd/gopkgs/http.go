@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var httpUsageInfo = `
+Serves the packages as JSON from an in-memory index, listed once on start. The endpoints are:
+	GET  /packages                 all packages
+	GET  /packages?pattern=net/... packages matching the import path pattern
+	GET  /search?q=json&limit=10   packages matching the query, best match first
+	GET  /package/{importpath}     the package of the import path
+	POST /refresh                  list the packages again, returning the added and removed import paths
+`
+
+func runHTTP(args []string) error {
+	fs := newFlagSet("http")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["http"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, httpUsageInfo)
+	}
+	lf := addListFlags(fs)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	ix := newIndex(opts)
+	if err := ix.ensure(); err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "serving %d packages on http://%s\n", len(ix.list("")), ln.Addr())
+	return http.Serve(ln, newHTTPHandler(ix))
+}
+
+func newHTTPHandler(ix *index) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/packages", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, nonNilPkgs(ix.list(r.URL.Query().Get("pattern"))))
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		var limit int
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", s))
+				return
+			}
+			limit = n
+		}
+		writeJSON(w, http.StatusOK, nonNilPkgs(ix.search(r.URL.Query().Get("q"), limit)))
+	})
+	mux.HandleFunc("/package/", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+
+		importPath := strings.TrimPrefix(r.URL.Path, "/package/")
+		pkg, found := ix.get(importPath)
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("package %q not found", importPath))
+			return
+		}
+		writeJSON(w, http.StatusOK, pkg)
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		added, removed, err := ix.refresh()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, refreshResult{
+			Packages: len(ix.list("")),
+			Added:    nonNilStrings(added),
+			Removed:  nonNilStrings(removed),
+		})
+	})
+	return mux
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
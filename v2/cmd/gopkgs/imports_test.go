@@ -0,0 +1,195 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAddImport(t *testing.T) {
+	cases := []struct {
+		name       string
+		src        string
+		importName string
+		importPath string
+		want       string
+		wantAdded  bool
+	}{
+		{
+			name:       "no import",
+			src:        "package p\n\nvar _ = 1\n",
+			importPath: "fmt",
+			want:       "package p\n\nimport \"fmt\"\n\nvar _ = 1\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "import comment",
+			src:        "// Package m does things.\npackage m // import \"example.com/m\"\n\nvar _ = 1\n",
+			importPath: "fmt",
+			want:       "// Package m does things.\npackage m // import \"example.com/m\"\n\nimport \"fmt\"\n\nvar _ = 1\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "block comment after the package clause",
+			src:        "package m /* import \"example.com/m\"\n */\n\nvar _ = 1\n",
+			importPath: "fmt",
+			want:       "package m /* import \"example.com/m\"\n */\n\nimport \"fmt\"\n\nvar _ = 1\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "already imported",
+			src:        "package p\n\nimport \"fmt\"\n",
+			importPath: "fmt",
+			want:       "package p\n\nimport \"fmt\"\n",
+		},
+		{
+			name:       "single import",
+			src:        "package p\n\nimport \"os\" // for Exit\n",
+			importPath: "fmt",
+			want:       "package p\n\nimport (\n\t\"fmt\"\n\t\"os\" // for Exit\n)\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "sorted on the group",
+			src:        "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"example.com/x\"\n)\n",
+			importPath: "io",
+			want:       "package p\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"os\"\n\n\t\"example.com/x\"\n)\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "new group",
+			src:        "package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/m/internal/x\"\n)\n",
+			importName: "yaml",
+			importPath: "gopkg.in/yaml.v3",
+			want:       "package p\n\nimport (\n\t\"fmt\"\n\n\tyaml \"gopkg.in/yaml.v3\"\n\n\t\"example.com/m/internal/x\"\n)\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "local group",
+			src:        "package p\n\nimport (\n\t\"fmt\"\n)\n",
+			importPath: "example.com/m/internal/x",
+			want:       "package p\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/m/internal/x\"\n)\n",
+			wantAdded:  true,
+		},
+		{
+			name: "comments",
+			src: `package p
+
+import ( // the imports
+	// standard library
+	"fmt" // for Println
+	// floating, between the specs
+
+	// os is for Exit
+	"os"
+
+	// floating, at the end
+) // after the declaration
+`,
+			importPath: "io",
+			want: `package p
+
+import ( // the imports
+	// standard library
+	"fmt" // for Println
+	// floating, between the specs
+
+	"io"
+	// os is for Exit
+	"os"
+	// floating, at the end
+) // after the declaration
+`,
+			wantAdded: true,
+		},
+		{
+			name: "after the last spec with comments",
+			src: `package p
+
+import (
+	"fmt" // for Println
+	// floating, at the end
+)
+`,
+			importPath: "os",
+			want: `package p
+
+import (
+	"fmt" // for Println
+	"os"
+	// floating, at the end
+)
+`,
+			wantAdded: true,
+		},
+		{
+			name:       "cgo",
+			src:        "package p\n\n// #include <stdio.h>\nimport \"C\"\n\nimport \"os\"\n",
+			importPath: "fmt",
+			want:       "package p\n\n// #include <stdio.h>\nimport \"C\"\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+			wantAdded:  true,
+		},
+		{
+			name:       "unsorted group",
+			src:        "package p\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+			importPath: "io",
+			want:       "package p\n\nimport (\n\t\"fmt\"\n\t\"io\"\n\t\"os\"\n)\n",
+			wantAdded:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, added, err := addImport([]byte(c.src), c.importName, c.importPath, "example.com/m")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if added != c.wantAdded {
+				t.Errorf("got added: %t, want: %t", added, c.wantAdded)
+			}
+
+			if string(out) != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", out, c.want)
+			}
+		})
+	}
+}
+
+func TestImportable(t *testing.T) {
+	cases := []struct {
+		importPath, pkgPath string
+		want                bool
+	}{
+		{importPath: "example.com/m/internal/x", pkgPath: "example.com/m", want: true},
+		{importPath: "example.com/m/internal/x", pkgPath: "example.com/m/cmd/app", want: true},
+		{importPath: "example.com/m/internal", pkgPath: "example.com/m/sub", want: true},
+		{importPath: "example.com/m/internal/x", pkgPath: "example.com/other", want: false},
+		{importPath: "example.com/m/internal/x", pkgPath: "example.com/mm", want: false},
+		{importPath: "internal/poll", pkgPath: "example.com/m", want: false},
+		{importPath: "example.com/m/vendor/github.com/x/y", pkgPath: "example.com/m", want: false},
+		{importPath: "vendor/golang.org/x/net/http2/hpack", pkgPath: "", want: false},
+		{importPath: "example.com/vendorlib", pkgPath: "example.com/m", want: true},
+		{importPath: "net/http", pkgPath: "", want: true},
+	}
+
+	for _, c := range cases {
+		if got := importable(c.importPath, c.pkgPath); got != c.want {
+			t.Errorf("importable(%q, %q) got: %t, want: %t", c.importPath, c.pkgPath, got, c.want)
+		}
+	}
+}
+
+func TestAssumedName(t *testing.T) {
+	cases := map[string]string{
+		"fmt":                    "fmt",
+		"gopkg.in/yaml.v2":       "yaml",
+		"example.com/go-pkg/v2":  "pkg",
+		"github.com/x/go-isatty": "isatty",
+		"example.com/v1":         "v1",
+	}
+
+	for importPath, want := range cases {
+		if got := assumedName(importPath); got != want {
+			t.Errorf("assumedName(%q) got: %q, want: %q", importPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+// index is the in-memory package listing, refreshed on demand.
+type index struct {
+	opts gopkgs.Options
+
+	mu     sync.RWMutex
+	pkgs   []gopkgs.Pkg          // sorted by import path
+	byPath map[string]gopkgs.Pkg // nil until the first refresh
+}
+
+func newIndex(opts gopkgs.Options) *index {
+	return &index{opts: opts}
+}
+
+// setWorkDir changes the working directory of the listing, it takes effect on the next refresh.
+func (ix *index) setWorkDir(workDir string) {
+	ix.mu.Lock()
+	ix.opts.WorkDir = workDir
+	ix.mu.Unlock()
+}
+
+// refresh lists the packages again, returning the import paths added and removed since the last listing.
+func (ix *index) refresh() (added, removed []string, err error) {
+	ix.mu.RLock()
+	opts := ix.opts
+	ix.mu.RUnlock()
+
+	result, err := gopkgs.List(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dirs := make([]string, 0, len(result))
+	for dir := range result {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	pkgs := make([]gopkgs.Pkg, 0, len(result))
+	byPath := make(map[string]gopkgs.Pkg, len(result))
+	for _, dir := range dirs {
+		pkg := result[dir]
+		if _, found := byPath[pkg.ImportPath]; found {
+			continue
+		}
+
+		byPath[pkg.ImportPath] = pkg
+		pkgs = append(pkgs, pkg)
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ImportPath < pkgs[j].ImportPath
+	})
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for importPath := range byPath {
+		if _, found := ix.byPath[importPath]; !found {
+			added = append(added, importPath)
+		}
+	}
+
+	for importPath := range ix.byPath {
+		if _, found := byPath[importPath]; !found {
+			removed = append(removed, importPath)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	ix.pkgs, ix.byPath = pkgs, byPath
+	return added, removed, nil
+}
+
+// loaded reports whether the packages have been listed.
+func (ix *index) loaded() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.byPath != nil
+}
+
+// ensure lists the packages if they have not been listed yet.
+func (ix *index) ensure() error {
+	if ix.loaded() {
+		return nil
+	}
+
+	_, _, err := ix.refresh()
+	return err
+}
+
+// get returns the package of the import path.
+func (ix *index) get(importPath string) (gopkgs.Pkg, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	pkg, found := ix.byPath[importPath]
+	return pkg, found
+}
+
+// list returns the packages matching the pattern, all packages if pattern is empty.
+func (ix *index) list(pattern string) []gopkgs.Pkg {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if pattern == "" {
+		return append([]gopkgs.Pkg(nil), ix.pkgs...)
+	}
+
+	match := gopkgs.MatchPattern(pattern)
+	var pkgs []gopkgs.Pkg
+	for _, pkg := range ix.pkgs {
+		if match(pkg.ImportPath) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// search returns the packages matching the query, best match first. The result is limited
+// to limit packages, unless it is 0.
+func (ix *index) search(query string, limit int) []gopkgs.Pkg {
+	type scored struct {
+		pkg   gopkgs.Pkg
+		score int
+	}
+
+	ix.mu.RLock()
+	var matches []scored
+	for _, pkg := range ix.pkgs {
+		if score := searchScore(pkg, query); score > 0 {
+			matches = append(matches, scored{pkg: pkg, score: score})
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return pkgRank(matches[i].pkg) < pkgRank(matches[j].pkg)
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	pkgs := make([]gopkgs.Pkg, len(matches))
+	for i, m := range matches {
+		pkgs[i] = m.pkg
+	}
+	return pkgs
+}
+
+// searchScore scores how well the package matches the query, 0 means no match.
+func searchScore(pkg gopkgs.Pkg, query string) int {
+	if query == "" {
+		return 1
+	}
+
+	q := strings.ToLower(query)
+	name := strings.ToLower(pkg.Name)
+	importPath := strings.ToLower(pkg.ImportPath)
+	switch {
+	case importPath == q:
+		return 7
+	case name == q:
+		return 6
+	case strings.HasPrefix(name, q):
+		return 5
+	case strings.HasSuffix(importPath, "/"+q):
+		return 4
+	case strings.Contains(name, q):
+		return 3
+	case strings.Contains(importPath, q):
+		return 2
+	case subsequence(importPath, q):
+		return 1
+	}
+	return 0
+}
+
+// subsequence reports whether the characters of sub appear in s in order.
+func subsequence(s, sub string) bool {
+	for _, c := range sub {
+		i := strings.IndexRune(s, c)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(c)):]
+	}
+	return true
+}
+
+// pkgRank orders the equally matched packages: standard library first, then the
+// shorter import path, then alphabetically.
+func pkgRank(pkg gopkgs.Pkg) string {
+	std := "1"
+	if pkg.Standard {
+		std = "0"
+	}
+	return std + string(rune('0'+strings.Count(pkg.ImportPath, "/"))) + pkg.ImportPath
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var lspUsageInfo = `
+Speaks JSON-RPC 2.0 over stdin and stdout, framed with Content-Length headers the same as
+the Language Server Protocol. The methods are:
+	initialize        {"rootUri": uri}, uses rootUri as workDir unless -workDir is set
+	shutdown, exit    ends the session
+	gopkgs/list       {"pattern": pattern} → [Pkg], all packages if pattern is empty
+	gopkgs/search     {"query": query, "limit": n} → [Pkg], best match first
+	gopkgs/refresh    → {"packages": n, "added": [importpath], "removed": [importpath]}
+
+When a refresh changes the packages listed before, the server sends the gopkgs/didChange
+notification with the added and removed import paths. Use -interval to refresh periodically.
+The workspace/didChangeWatchedFiles notification has the packages listed again on the next
+gopkgs/list or gopkgs/search.
+`
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcRequest is the JSON-RPC request, or the notification if ID is empty.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is the JSON-RPC response, holding either Result or Error.
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcNotification is the JSON-RPC notification sent by the server.
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// changeParams is the params of the gopkgs/didChange notification.
+type changeParams struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// refreshResult is the result of gopkgs/refresh.
+type refreshResult struct {
+	Packages int      `json:"packages"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+}
+
+func runLSP(args []string) error {
+	fs := newFlagSet("lsp")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["lsp"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, lspUsageInfo)
+	}
+	lf := addListFlags(fs)
+	interval := fs.Duration("interval", 0, "refresh the packages periodically, 0 means only on gopkgs/refresh")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	s := &lspServer{
+		ix:      newIndex(opts),
+		workDir: opts.WorkDir != "",
+		in:      bufio.NewReader(os.Stdin),
+		out:     os.Stdout,
+	}
+
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				if _, err := s.refresh(); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}()
+	}
+
+	return s.serve()
+}
+
+// lspServer serves the index over JSON-RPC.
+type lspServer struct {
+	ix       *index
+	workDir  bool // workDir set by flag, rootUri of initialize is ignored
+	shutdown bool
+	stale    bool // files changed since the last listing
+
+	in  *bufio.Reader
+	mu  sync.Mutex // guards out
+	out io.Writer
+}
+
+// serve handles the messages until exit, or the end of the input.
+func (s *lspServer) serve() error {
+	for {
+		data, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			if err := s.reply(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(req.ID) == 0 {
+			switch req.Method {
+			case "exit":
+				if !s.shutdown {
+					return exitCode(1)
+				}
+				return nil
+			case "workspace/didChangeWatchedFiles":
+				s.stale = true
+			}
+			continue
+		}
+
+		if req.Method == "" {
+			// response to the server, nothing is requested from the client.
+			continue
+		}
+
+		result, err := s.handle(req)
+		if err := s.reply(req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) handle(req rpcRequest) (interface{}, error) {
+	if s.shutdown {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			RootURI string `json:"rootUri"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if !s.workDir && params.RootURI != "" {
+			dir, err := uriPath(params.RootURI)
+			if err != nil {
+				return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			}
+			s.ix.setWorkDir(dir)
+		}
+
+		if _, err := s.refresh(); err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{},
+			"serverInfo":   map[string]string{"name": "gopkgs"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "gopkgs/list":
+		var params struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if err := s.ensure(); err != nil {
+			return nil, err
+		}
+		return nonNilPkgs(s.ix.list(params.Pattern)), nil
+	case "gopkgs/search":
+		var params struct {
+			Query string `json:"query"`
+			Limit int    `json:"limit"`
+		}
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if err := s.ensure(); err != nil {
+			return nil, err
+		}
+		return nonNilPkgs(s.ix.search(params.Query, params.Limit)), nil
+	case "gopkgs/refresh":
+		s.stale = false
+		return s.refresh()
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// ensure lists the packages if they have not been listed yet, or again if files changed.
+func (s *lspServer) ensure() error {
+	if s.stale && s.ix.loaded() {
+		s.stale = false
+		_, err := s.refresh()
+		return err
+	}
+
+	if err := s.ix.ensure(); err != nil {
+		return &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+// refresh lists the packages again, notifying the client if the packages changed since
+// the previous listing.
+func (s *lspServer) refresh() (*refreshResult, error) {
+	loaded := s.ix.loaded()
+	added, removed, err := s.ix.refresh()
+	if err != nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+
+	if loaded && (len(added) > 0 || len(removed) > 0) {
+		err := s.write(rpcNotification{
+			JSONRPC: "2.0",
+			Method:  "gopkgs/didChange",
+			Params:  changeParams{Added: nonNilStrings(added), Removed: nonNilStrings(removed)},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &refreshResult{
+		Packages: len(s.ix.list("")),
+		Added:    nonNilStrings(added),
+		Removed:  nonNilStrings(removed),
+	}, nil
+}
+
+func (s *lspServer) reply(id json.RawMessage, result interface{}, err error) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	resp := rpcResponse{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		rerr, ok := err.(*rpcError)
+		if !ok {
+			rerr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		resp.Error = rerr
+		return s.write(resp)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	raw := json.RawMessage(data)
+	resp.Result = &raw
+	return s.write(resp)
+}
+
+// write sends the message with the Content-Length header.
+func (s *lspServer) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(data)
+	return err
+}
+
+// readMessage reads the content of the next message framed with the Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" && length < 0 {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fail reading message header: %v", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid message header %q", line)
+		}
+
+		if strings.EqualFold(strings.TrimSpace(line[:i]), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(line[i+1:]))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", line[i+1:])
+			}
+			length = n
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("fail reading message: %v", err)
+	}
+	return data, nil
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// uriPath returns the file path of the file URI.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %q, only file URI is supported", uri)
+	}
+
+	path := u.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		// file:///C:/dir on Windows
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+func nonNilPkgs(pkgs []gopkgs.Pkg) []gopkgs.Pkg {
+	if pkgs == nil {
+		return []gopkgs.Pkg{}
+	}
+	return pkgs
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestReadMessage(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "single",
+			input: "Content-Length: 2\r\n\r\n{}",
+			want:  []string{"{}"},
+		},
+		{
+			name:  "consecutive with other headers",
+			input: "Content-Length: 2\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{}content-length:7\n\n[1,2,3]",
+			want:  []string{"{}", "[1,2,3]"},
+		},
+		{
+			name:    "missing length",
+			input:   "Content-Type: application/json\r\n\r\n{}",
+			wantErr: "missing Content-Length header",
+		},
+		{
+			name:    "invalid length",
+			input:   "Content-Length: -1\r\n\r\n",
+			wantErr: "invalid Content-Length",
+		},
+		{
+			name:    "invalid header",
+			input:   "Content-Length 2\r\n\r\n{}",
+			wantErr: "invalid message header",
+		},
+		{
+			name:    "truncated body",
+			input:   "Content-Length: 10\r\n\r\n{}",
+			wantErr: "fail reading message",
+		},
+		{
+			name:    "truncated header",
+			input:   "Content-Length: 2\r\n",
+			wantErr: "fail reading message header",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(c.input))
+			for _, want := range c.want {
+				got, err := readMessage(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(got) != want {
+					t.Errorf("got: %q, want: %q", got, want)
+				}
+			}
+
+			_, err := readMessage(r)
+			switch {
+			case c.wantErr == "" && err != io.EOF:
+				t.Errorf("got error: %v, want: EOF", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Errorf("got error: %v, want containing: %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestURIPath(t *testing.T) {
+	cases := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{uri: "file:///home/me/project", want: "/home/me/project"},
+		{uri: "file:///home/me/my%20project", want: "/home/me/my project"},
+		{uri: "https://example.com/project", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := uriPath(c.uri)
+		if (err != nil) != c.wantErr {
+			t.Errorf("uriPath(%q) error: %v, want error: %t", c.uri, err, c.wantErr)
+			continue
+		}
+
+		if got != c.want {
+			t.Errorf("uriPath(%q) got: %q, want: %q", c.uri, got, c.want)
+		}
+	}
+}
+
+// lspClient drives the server over in-memory pipes.
+type lspClient struct {
+	t      *testing.T
+	in     *io.PipeWriter // requests to the server
+	out    *bufio.Reader  // messages from the server
+	nextID int
+
+	notifications []rpcNotification
+	done          chan error // result of serve
+}
+
+func newLSPClient(t *testing.T, ix *index) *lspClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &lspServer{ix: ix, in: bufio.NewReader(inR), out: outW}
+
+	c := &lspClient{t: t, in: inW, out: bufio.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		err := s.serve()
+		outW.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *lspClient) send(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// call sends the request, returning its response. The notifications received meanwhile
+// are kept on notifications.
+func (c *lspClient) call(method string, params interface{}) rpcResponse {
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+
+	for {
+		data, err := readMessage(c.out)
+		if err != nil {
+			c.t.Fatalf("%s: fail reading response: %v", method, err)
+		}
+
+		var msg struct {
+			rpcResponse
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+
+		if msg.Method != "" {
+			var params changeParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				c.t.Fatal(err)
+			}
+			c.notifications = append(c.notifications, rpcNotification{JSONRPC: "2.0", Method: msg.Method, Params: params})
+			continue
+		}
+
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("%s: got response id %s, want: %s", method, msg.ID, id)
+		}
+		return msg.rpcResponse
+	}
+}
+
+// result calls the method, decoding its result into v.
+func (c *lspClient) result(method string, params interface{}, v interface{}) {
+	resp := c.call(method, params)
+	if resp.Error != nil {
+		c.t.Fatalf("%s: got error: %v", method, resp.Error)
+	}
+
+	if err := json.Unmarshal(*resp.Result, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// exit sends the exit notification, returning the result of serve.
+func (c *lspClient) exit() error {
+	c.send(map[string]string{"jsonrpc": "2.0", "method": "exit"})
+	c.in.Close()
+	return <-c.done
+}
+
+func importPaths(pkgs []gopkgs.Pkg) []string {
+	paths := []string{}
+	for _, pkg := range pkgs {
+		paths = append(paths, pkg.ImportPath)
+	}
+	return paths
+}
+
+func TestLSPSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	dir := testModule(t, map[string]string{
+		"lib/lib.go":     "package lib\n",
+		"lib/libutil.go": "package lib\n",
+		"liber/liber.go": "package liber\n",
+	})
+	c := newLSPClient(t, newIndex(gopkgs.Options{}))
+
+	var init struct {
+		ServerInfo struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	c.result("initialize", map[string]string{"rootUri": "file://" + filepath.ToSlash(dir)}, &init)
+	if init.ServerInfo.Name != "gopkgs" {
+		t.Errorf("got server name: %q, want: gopkgs", init.ServerInfo.Name)
+	}
+
+	var pkgs []gopkgs.Pkg
+	c.result("gopkgs/list", map[string]string{"pattern": "example.com/m/..."}, &pkgs)
+	if got, want := importPaths(pkgs), []string{"example.com/m/lib", "example.com/m/liber"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list got: %v, want: %v", got, want)
+	}
+
+	c.result("gopkgs/search", map[string]interface{}{"query": "lib", "limit": 1}, &pkgs)
+	if got, want := importPaths(pkgs), []string{"example.com/m/lib"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("search got: %v, want: %v", got, want)
+	}
+
+	writeFiles(t, dir, map[string]string{"lib/sub/sub.go": "package sub\n"})
+	var refreshed refreshResult
+	c.result("gopkgs/refresh", nil, &refreshed)
+	if want := []string{"example.com/m/lib/sub"}; !reflect.DeepEqual(refreshed.Added, want) || len(refreshed.Removed) != 0 {
+		t.Errorf("refresh got added: %v, removed: %v, want added: %v", refreshed.Added, refreshed.Removed, want)
+	}
+
+	wantChange := rpcNotification{JSONRPC: "2.0", Method: "gopkgs/didChange", Params: changeParams{Added: []string{"example.com/m/lib/sub"}, Removed: []string{}}}
+	if len(c.notifications) != 1 || !reflect.DeepEqual(c.notifications[0], wantChange) {
+		t.Errorf("refresh got notifications: %v, want: %v", c.notifications, wantChange)
+	}
+
+	// listed again only after the files are reported changed
+	if err := os.RemoveAll(filepath.Join(dir, "liber")); err != nil {
+		t.Fatal(err)
+	}
+	c.result("gopkgs/list", map[string]string{"pattern": "example.com/m/..."}, &pkgs)
+	if got, want := importPaths(pkgs), []string{"example.com/m/lib", "example.com/m/lib/sub", "example.com/m/liber"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list before didChangeWatchedFiles got: %v, want: %v", got, want)
+	}
+
+	c.send(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "workspace/didChangeWatchedFiles",
+		"params":  map[string]interface{}{"changes": []map[string]interface{}{{"uri": "file://" + filepath.ToSlash(dir) + "/liber/liber.go", "type": 3}}},
+	})
+	c.result("gopkgs/list", map[string]string{"pattern": "example.com/m/..."}, &pkgs)
+	if got, want := importPaths(pkgs), []string{"example.com/m/lib", "example.com/m/lib/sub"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list after didChangeWatchedFiles got: %v, want: %v", got, want)
+	}
+
+	wantChange.Params = changeParams{Added: []string{}, Removed: []string{"example.com/m/liber"}}
+	if len(c.notifications) != 2 || !reflect.DeepEqual(c.notifications[1], wantChange) {
+		t.Errorf("didChangeWatchedFiles got notifications: %v, want: %v", c.notifications, wantChange)
+	}
+
+	if resp := c.call("shutdown", nil); resp.Error != nil {
+		t.Errorf("shutdown got error: %v", resp.Error)
+	}
+
+	if err := c.exit(); err != nil {
+		t.Errorf("exit got: %v, want: nil", err)
+	}
+}
+
+func TestLSPMethods(t *testing.T) {
+	ix := testIndex(
+		gopkgs.Pkg{ImportPath: "encoding/json", Name: "json", Standard: true},
+		gopkgs.Pkg{ImportPath: "net/http", Name: "http", Standard: true},
+		gopkgs.Pkg{ImportPath: "net/url", Name: "url", Standard: true},
+	)
+	c := newLSPClient(t, ix)
+
+	var pkgs []gopkgs.Pkg
+	c.result("gopkgs/list", map[string]string{"pattern": "net/..."}, &pkgs)
+	if got, want := importPaths(pkgs), []string{"net/http", "net/url"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list got: %v, want: %v", got, want)
+	}
+
+	c.result("gopkgs/list", nil, &pkgs)
+	if got := len(pkgs); got != 3 {
+		t.Errorf("list without params got %d packages, want: 3", got)
+	}
+
+	c.result("gopkgs/search", map[string]interface{}{"query": "n", "limit": 2}, &pkgs)
+	if got := len(pkgs); got != 2 {
+		t.Errorf("search with limit 2 got %d packages", got)
+	}
+
+	cases := []struct {
+		method   string
+		params   interface{}
+		wantCode int
+	}{
+		{method: "textDocument/completion", wantCode: rpcMethodNotFound},
+		{method: "gopkgs/search", params: map[string]string{"limit": "ten"}, wantCode: rpcInvalidParams},
+	}
+
+	for _, cs := range cases {
+		resp := c.call(cs.method, cs.params)
+		if resp.Error == nil || resp.Error.Code != cs.wantCode {
+			t.Errorf("%s got error: %v, want code: %d", cs.method, resp.Error, cs.wantCode)
+		}
+	}
+
+	if err := c.exit(); err != exitCode(1) {
+		t.Errorf("exit without shutdown got: %v, want: %v", err, exitCode(1))
+	}
+}
+
+func TestLSPShutdown(t *testing.T) {
+	c := newLSPClient(t, testIndex())
+	if resp := c.call("shutdown", nil); resp.Error != nil {
+		t.Fatalf("shutdown got error: %v", resp.Error)
+	}
+
+	if resp := c.call("gopkgs/list", nil); resp.Error == nil || resp.Error.Code != rpcInvalidRequest {
+		t.Errorf("list after shutdown got error: %v, want code: %d", resp.Error, rpcInvalidRequest)
+	}
+
+	if err := c.exit(); err != nil {
+		t.Errorf("exit after shutdown got: %v, want: nil", err)
+	}
+}
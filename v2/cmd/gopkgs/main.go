@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
-	"html/template"
 	"os"
 	"runtime/pprof"
 	"runtime/trace"
@@ -16,21 +15,72 @@ import (
 var usageInfo = `
 Use -format to custom the output using template syntax. The struct being passed to template is:
 	type Pkg struct {
-		Dir        string // directory containing package sources
-		ImportPath string // import path of package in dir
-		Name       string // package name
-		Standard   bool   // is this package part of the standard Go library?
+		Dir         string // directory containing package sources
+		ImportPath  string // import path of package in dir
+		Name        string // package name
+		Standard    bool   // is this package part of the standard Go library?
+		Root        string // root directory the package found on: GOROOT or GOPATH src directory, or module directory
+		Module      string // path of the module containing the package, only in module mode
+		Version     string // version of the module containing the package, if known
+		NotRequired bool   // is the module only in the module cache, not required by the main modules? (-modcache)
+
+		// File listing, only available with -files
+		GoFiles      []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
+		CgoFiles     []string // .go source files that import "C"
+		TestGoFiles  []string // _test.go files in package
+		XTestGoFiles []string // _test.go files outside package
+		XTestName    string   // name of the external test package, if any
+		IgnoredFiles []string // .go source files ignored due to build constraints
+
+		Imports []string // import paths used by this package, only available with -imports
 	}
 
+Use -0 to terminate each output with NUL instead of newline, e.g. for xargs -0 or fzf --read0.
+Use -csv or -tsv to print the Pkg fields listed on -fields as CSV or TSV with a header row.
+
+Use -tree to print the packages as a tree grouped by standard library, module and GOPATH root,
+along with the number of packages under each node. Use -depth to limit the levels printed.
+
+Use -exclude to skip the directories, e.g. -exclude=bazel-out,/third_party/js. The .gopkgsignore
+files (gitignore syntax) on the roots, their sub directories and workDir are honoured the same way.
+
 Use -workDir={path} to speed up the package search. This will ignore any vendor package outside the package root.
+In module mode, the vendor directories and the nested modules (sub directories having their own go.mod)
+are not walked as part of the module, the same as go list ./... does.
 `
 
+var (
+	flagFormat      = flag.String("format", "{{.ImportPath}}", "custom output format")
+	flagWorkDir     = flag.String("workDir", "", "importable packages only for workDir")
+	flagNoVendor    = flag.Bool("no-vendor", false, "exclude vendor dependencies except under workDir (if specified)")
+	flagExclude     = flag.String("exclude", "", excludeUsage)
+	flagGitIgnore   = flag.Bool("gitignore", false, "skip the directories ignored by the .gitignore files")
+	flagModCache    = flag.Bool("modcache", false, "also list the latest cached version of the modules not required, only in module mode")
+	flagConcurrency = flag.Int("concurrency", 0, "number of directories walked concurrently, 0 means the number of CPUs")
+	flagOverlay     = flag.String("overlay", "", "JSON file replacing the file contents, same as go build -overlay")
+	flagFiles       = flag.Bool("files", false, "retrieve the file listing of each package")
+	flagImports     = flag.Bool("imports", false, "retrieve the imports of each package")
+	flagTree        = flag.Bool("tree", false, "print the packages as a tree grouped by import path")
+	flagDepth       = flag.Int("depth", 0, "limit the levels of -tree, 0 means no limit")
+	flagNul         = flag.Bool("0", false, "terminate each -format output with NUL instead of newline")
+	flagCSV         = flag.Bool("csv", false, "print the packages as CSV with a header row")
+	flagTSV         = flag.Bool("tsv", false, "print the packages as TSV with a header row")
+	flagFields      = flag.String("fields", defaultFields, "comma separated Pkg fields of -csv and -tsv")
+	flagStats       = flag.Bool("stats", false, "print the details of the listing to stderr")
+	flagHelp        = flag.Bool("help", false, "show this message")
+)
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr)
+	printCommands()
 	tw := tabwriter.NewWriter(os.Stderr, 0, 0, 4, ' ', tabwriter.AlignRight)
-	fmt.Fprintln(tw, usageInfo)
+	fmt.Fprint(tw, usageInfo)
+	fmt.Fprintln(tw, formatUsageInfo)
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
 
 func init() {
@@ -38,11 +88,21 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				if code, ok := err.(exitCode); ok {
+					os.Exit(int(code))
+				}
+
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var (
-		flagFormat         = flag.String("format", "{{.ImportPath}}", "custom output format")
-		flagWorkDir        = flag.String("workDir", "", "importable packages only for workDir")
-		flagNoVendor       = flag.Bool("no-vendor", false, "exclude vendor dependencies except under workDir (if specified)")
-		flagHelp           = flag.Bool("help", false, "show this message")
 		flagPerfCPUProfile *string
 		flagPerfTrace      *string
 	)
@@ -59,6 +119,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	cfgs, err := loadConfigs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if _, err = applyConfig(flag.CommandLine, "", cfgs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if countTrue(*flagTree, *flagCSV, *flagTSV, *flagNul) > 1 {
+		fmt.Fprintln(os.Stderr, "only one of -tree, -csv, -tsv and -0 can be used")
+		os.Exit(1)
+	}
+
 	if flagPerfCPUProfile != nil && *flagPerfCPUProfile != "" {
 		pf, err := os.Create(*flagPerfCPUProfile)
 		if err != nil {
@@ -101,21 +177,44 @@ func main() {
 		defer trace.Stop()
 	}
 
-	tpl, err := template.New("out").Parse(*flagFormat)
+	tpl, err := parseFormat(*flagFormat, *flagWorkDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	overlay, err := readOverlay(*flagOverlay)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	pkgs, err := gopkgs.List(gopkgs.Options{
-		WorkDir:  *flagWorkDir,
-		NoVendor: *flagNoVendor,
+	result, err := gopkgs.ListWithResult(gopkgs.Options{
+		WorkDir:         *flagWorkDir,
+		NoVendor:        *flagNoVendor,
+		IncludeModCache: *flagModCache,
+		Exclude:         splitList(*flagExclude),
+		GitIgnore:       *flagGitIgnore,
+		Files:           *flagFiles,
+		Imports:         *flagImports,
+		Concurrency:     *flagConcurrency,
+		Overlay:         overlay,
 	})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
+	if *flagStats {
+		defer func() {
+			if err := printStats(os.Stderr, result); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+
+	pkgs := result.Pkgs
+
 	w := bufio.NewWriter(os.Stdout)
 	defer func() {
 		if err := w.Flush(); err != nil {
@@ -124,11 +223,44 @@ func main() {
 		}
 	}()
 
-	for _, pkg := range pkgs {
-		if err := tpl.Execute(w, pkg); err != nil {
+	if *flagTree {
+		if err := printTree(w, buildTree(pkgs), *flagDepth); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagCSV || *flagTSV {
+		write := writeCSV
+		if *flagTSV {
+			write = writeTSV
+		}
+
+		if err := write(w, pkgs, *flagFields); err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		fmt.Fprintln(w)
+		return
+	}
+
+	terminator := "\n"
+	if *flagNul {
+		terminator = "\x00"
+	}
+
+	if err := writeFormat(w, pkgs, tpl, terminator); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func countTrue(flags ...bool) int {
+	var n int
+	for _, f := range flags {
+		if f {
+			n++
+		}
 	}
+	return n
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestMain(m *testing.M) {
+	// keep the user config and environment out of the commands under test
+	dir, err := ioutil.TempDir("", "gopkgs-config")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fail creating config dir:", err)
+		os.Exit(1)
+	}
+
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GOPKGS_") {
+			os.Unsetenv(kv[:strings.IndexByte(kv, '=')])
+		}
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func setenv(t *testing.T, key, value string) func() {
+	old, found := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if found {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func chdir(t *testing.T, dir string) func() {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeFiles writes the files, keyed by slash-separated path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testModule creates the module example.com/m with the files, returning its directory.
+func testModule(t *testing.T, files map[string]string) string {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, map[string]string{"go.mod": "module example.com/m\n\ngo 1.16\n"})
+	writeFiles(t, dir, files)
+	return dir
+}
+
+// captureStdout runs f, returning what it prints to stdout.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	tmp, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmp.Close()
+
+	stdout := os.Stdout
+	os.Stdout = tmp
+	ferr := f()
+	os.Stdout = stdout
+
+	out, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), ferr
+}
+
+// testIndex returns the index of the packages, sorted by import path, without listing.
+func testIndex(pkgs ...gopkgs.Pkg) *index {
+	ix := &index{byPath: make(map[string]gopkgs.Pkg)}
+	for _, pkg := range pkgs {
+		ix.byPath[pkg.ImportPath] = pkg
+	}
+
+	for _, pkg := range ix.byPath {
+		ix.pkgs = append(ix.pkgs, pkg)
+	}
+	sort.Slice(ix.pkgs, func(i, j int) bool {
+		return ix.pkgs[i].ImportPath < ix.pkgs[j].ImportPath
+	})
+	return ix
+}
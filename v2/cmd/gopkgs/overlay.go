@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// readOverlay reads the overlay file, in the same format as "go build -overlay":
+//
+//	{"Replace": {"/path/to/file.go": "/path/to/unsaved/file.go"}}
+//
+// Files replaced by an empty path are ignored, deleting files is not supported.
+func readOverlay(filename string) (map[string][]byte, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlay struct {
+		Replace map[string]string
+	}
+	if err = json.Unmarshal(b, &overlay); err != nil {
+		return nil, err
+	}
+
+	contents := make(map[string][]byte, len(overlay.Replace))
+	for path, replacement := range overlay.Replace {
+		if replacement == "" {
+			continue
+		}
+
+		if contents[path], err = ioutil.ReadFile(replacement); err != nil {
+			return nil, err
+		}
+	}
+	return contents, nil
+}
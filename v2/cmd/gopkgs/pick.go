@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+var pickUsageInfo = `
+Picks a package interactively on the terminal, filtering the packages as the query is typed.
+The chosen import path is printed, or with -file imported into the Go file.
+
+Keys:
+	Up, Ctrl-P, Ctrl-K    previous package
+	Down, Ctrl-N, Ctrl-J  next package
+	Enter                 choose the package
+	Ctrl-U, Ctrl-W        clear the query, delete the last word or path element
+	Esc, Ctrl-C           cancel
+
+Without a terminal supporting the raw mode, the packages are picked by number instead.
+`
+
+func runPick(args []string) error {
+	fs := newFlagSet("pick")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n", os.Args[0], commands["pick"].usage)
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, pickUsageInfo)
+	}
+	lf := addListFlags(fs)
+	file := fs.String("file", "", "import the chosen package into the Go file instead of printing it")
+	query := fs.String("query", "", "initial query")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	opts, err := lf.options()
+	if err != nil {
+		return err
+	}
+
+	if opts.WorkDir == "" {
+		dir := "."
+		if *file != "" {
+			dir = filepath.Dir(*file)
+		}
+
+		if opts.WorkDir, err = filepath.Abs(dir); err != nil {
+			return err
+		}
+	}
+
+	ix := newIndex(opts)
+	if err := ix.ensure(); err != nil {
+		return err
+	}
+
+	pkg, ok, err := pickPkg(ix, *query)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return exitCode(1)
+	}
+
+	if *file == "" {
+		fmt.Println(pkg.ImportPath)
+		return nil
+	}
+
+	added, err := addImportFile(*file, importName(pkg.ImportPath, pkg.Name), pkg.ImportPath)
+	if err != nil {
+		return err
+	}
+
+	if !added {
+		fmt.Fprintf(os.Stderr, "%s already imports %s\n", *file, pkg.ImportPath)
+	}
+	return nil
+}
+
+// pickPkg picks the package on the terminal, falling back to the line mode on stdin.
+func pickPkg(ix *index, query string) (gopkgs.Pkg, bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err == nil {
+		defer tty.Close()
+
+		restore, err := makeRaw(int(tty.Fd()))
+		if err == nil {
+			p := &picker{
+				ix:       ix,
+				fd:       int(tty.Fd()),
+				in:       bufio.NewReader(tty),
+				out:      bufio.NewWriter(tty),
+				query:    []rune(query),
+				synopses: make(map[string]string),
+			}
+
+			pkg, ok, err := p.run()
+			if rerr := restore(); err == nil {
+				err = rerr
+			}
+			return pkg, ok, err
+		}
+	}
+
+	return linePick(ix, query, os.Stdin, os.Stderr)
+}
+
+// picker is the terminal UI picking a package.
+type picker struct {
+	ix  *index
+	fd  int
+	in  *bufio.Reader
+	out *bufio.Writer
+
+	query   []rune
+	matches []gopkgs.Pkg
+	cursor  int // selected match
+	offset  int // first match shown
+
+	synopses map[string]string // keyed by directory
+}
+
+// Picker keys.
+const (
+	keyCtrlC     = 3
+	keyCtrlJ     = 10
+	keyCtrlK     = 11
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEsc       = 27
+	keyBackspace = 127
+	keyCtrlH     = 8
+)
+
+// escTimeout is how long the rest of an escape sequence is waited for after ESC, the ESC
+// alone cancelling the picker.
+var escTimeout = 50 * time.Millisecond
+
+// key is a rune read from the terminal, or the error reading it.
+type key struct {
+	r   rune
+	err error
+}
+
+func (p *picker) run() (gopkgs.Pkg, bool, error) {
+	// alternate screen, restored on return
+	fmt.Fprint(p.out, "\x1b[?1049h")
+	defer func() {
+		fmt.Fprint(p.out, "\x1b[?1049l")
+		p.out.Flush()
+	}()
+
+	// the terminal is read on its own goroutine, so that the escape sequences split
+	// across reads are waited for with a timeout
+	keys := make(chan key)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			r, _, err := p.in.ReadRune()
+			select {
+			case keys <- key{r, err}:
+			case <-done:
+				return
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	p.filter()
+	for {
+		if err := p.draw(); err != nil {
+			return gopkgs.Pkg{}, false, err
+		}
+
+		k := <-keys
+		if k.err != nil {
+			return gopkgs.Pkg{}, false, k.err
+		}
+
+		switch k.r {
+		case keyEnter:
+			if len(p.matches) == 0 {
+				continue
+			}
+			return p.matches[p.cursor], true, nil
+		case keyCtrlC:
+			return gopkgs.Pkg{}, false, nil
+		case keyEsc:
+			ok, err := p.escape(keys)
+			if err != nil {
+				return gopkgs.Pkg{}, false, err
+			}
+
+			if !ok {
+				return gopkgs.Pkg{}, false, nil
+			}
+		case keyCtrlP, keyCtrlK:
+			p.move(-1)
+		case keyCtrlN, keyCtrlJ:
+			p.move(1)
+		case keyBackspace, keyCtrlH:
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case keyCtrlU:
+			p.query = p.query[:0]
+			p.filter()
+		case keyCtrlW:
+			p.query = []rune(deleteWord(string(p.query)))
+			p.filter()
+		default:
+			if unicode.IsPrint(k.r) {
+				p.query = append(p.query, k.r)
+				p.filter()
+			}
+		}
+	}
+}
+
+// escape handles the escape sequences of the arrow keys read after ESC, e.g. ESC [ A for up.
+// It reports false for the ESC key itself, not followed by a sequence within escTimeout.
+func (p *picker) escape(keys <-chan key) (bool, error) {
+	next := func() (rune, bool, error) {
+		select {
+		case k := <-keys:
+			return k.r, true, k.err
+		case <-time.After(escTimeout):
+			return 0, false, nil
+		}
+	}
+
+	r, ok, err := next()
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if r != '[' && r != 'O' {
+		return true, nil
+	}
+
+	if r, ok, err = next(); err != nil || !ok {
+		return true, err
+	}
+
+	switch r {
+	case 'A':
+		p.move(-1)
+	case 'B':
+		p.move(1)
+	}
+	return true, nil
+}
+
+// deleteWord deletes the last word or path element of the query, along with the spaces and
+// separators following it, e.g. "net/http/" becomes "net/".
+func deleteWord(query string) string {
+	query = strings.TrimRightFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/'
+	})
+	return query[:strings.LastIndexAny(query, " /")+1]
+}
+
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+func (p *picker) filter() {
+	p.matches = p.ix.search(string(p.query), 0)
+	p.cursor, p.offset = 0, 0
+}
+
+// draw renders the query, the matches and the preview of the selected package.
+func (p *picker) draw() error {
+	width, height, err := terminalSize(p.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	const previewRows = 4 // separator, Dir, Name, synopsis
+	listRows := height - 2 - previewRows
+	if listRows < 1 {
+		listRows = 1
+	}
+
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+listRows {
+		p.offset = p.cursor - listRows + 1
+	}
+
+	w := p.out
+	fmt.Fprint(w, "\x1b[H")
+	line := func(s string) {
+		fmt.Fprint(w, truncate(s, width), "\x1b[K\r\n")
+	}
+
+	line("> " + string(p.query))
+	line(fmt.Sprintf("  %d/%d", len(p.matches), len(p.ix.list(""))))
+	for i := p.offset; i < p.offset+listRows; i++ {
+		if i >= len(p.matches) {
+			line("")
+			continue
+		}
+
+		if i == p.cursor {
+			fmt.Fprint(w, "\x1b[7m")
+			line("> " + p.matches[i].ImportPath)
+			fmt.Fprint(w, "\x1b[0m")
+			continue
+		}
+		line("  " + p.matches[i].ImportPath)
+	}
+
+	line(strings.Repeat("─", width))
+	if len(p.matches) > 0 {
+		pkg := p.matches[p.cursor]
+		line("Dir:  " + pkg.Dir)
+		line("Name: " + pkg.Name)
+		fmt.Fprint(w, truncate(p.synopsis(pkg), width), "\x1b[K")
+	}
+	fmt.Fprint(w, "\x1b[J")
+
+	// cursor at the end of the query
+	fmt.Fprintf(w, "\x1b[1;%dH", utf8.RuneCountInString(truncate("> "+string(p.query), width))+1)
+	return w.Flush()
+}
+
+// synopsis returns the first sentence of the package documentation, cached by directory.
+func (p *picker) synopsis(pkg gopkgs.Pkg) string {
+	if s, ok := p.synopses[pkg.Dir]; ok {
+		return s
+	}
+
+	s := readSynopsis(pkg.Dir)
+	p.synopses[pkg.Dir] = s
+	return s
+}
+
+// readSynopsis returns the first sentence of the package documentation on dir.
+func readSynopsis(dir string) string {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	fset := token.NewFileSet()
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || f.Doc == nil {
+			continue
+		}
+		return firstSentence(f.Doc.Text())
+	}
+	return ""
+}
+
+// firstSentence returns the text up to the first period followed by space, or the first
+// blank line, on a single line.
+func firstSentence(text string) string {
+	if i := strings.Index(text, "\n\n"); i >= 0 {
+		text = text[:i]
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if i := strings.Index(text, ". "); i >= 0 {
+		text = text[:i+1]
+	}
+	return text
+}
+
+// truncate cuts s to the width, in runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// linePick picks the package by number, reading the query and the choice line by line.
+func linePick(ix *index, query string, in io.Reader, out io.Writer) (gopkgs.Pkg, bool, error) {
+	const maxMatches = 20
+
+	r := bufio.NewReader(in)
+	readLine := func(prompt string) (string, bool) {
+		fmt.Fprint(out, prompt)
+		s, err := r.ReadString('\n')
+		if err != nil && s == "" {
+			return "", false
+		}
+		return strings.TrimSpace(s), true
+	}
+
+	for {
+		if query == "" {
+			var ok bool
+			if query, ok = readLine("query: "); !ok {
+				return gopkgs.Pkg{}, false, nil
+			}
+		}
+
+		matches := ix.search(query, maxMatches)
+		if len(matches) == 0 {
+			fmt.Fprintln(out, "no package matches", query)
+			query = ""
+			continue
+		}
+
+		for i, pkg := range matches {
+			fmt.Fprintf(out, "%2d) %s\n", i+1, pkg.ImportPath)
+		}
+
+		s, ok := readLine("number (empty to search again): ")
+		if !ok {
+			return gopkgs.Pkg{}, false, nil
+		}
+
+		query = ""
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > len(matches) {
+			fmt.Fprintln(out, "invalid number", s)
+			continue
+		}
+		return matches[n-1], true, nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestDeleteWord(t *testing.T) {
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: ""},
+		{query: "http", want: ""},
+		{query: "net/http", want: "net/"},
+		{query: "net/http/", want: "net/"},
+		{query: "net//", want: ""},
+		{query: "json encoding ", want: "json "},
+		{query: "github.com/x/ ", want: "github.com/"},
+	}
+
+	for _, c := range cases {
+		if got := deleteWord(c.query); got != c.want {
+			t.Errorf("deleteWord(%q) got: %q, want: %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestPickerKeys(t *testing.T) {
+	defer func(timeout time.Duration) { escTimeout = timeout }(escTimeout)
+	escTimeout = 200 * time.Millisecond
+
+	ix := testIndex(
+		gopkgs.Pkg{ImportPath: "net/http", Name: "http"},
+		gopkgs.Pkg{ImportPath: "net/http/httptest", Name: "httptest"},
+		gopkgs.Pkg{ImportPath: "net/url", Name: "url"},
+	)
+
+	// the matches of the empty query: net/http, net/url, net/http/httptest
+	cases := []struct {
+		name   string
+		reads  []string // written to the terminal one read at a time
+		want   string
+		wantOK bool
+	}{
+		{name: "enter", reads: []string{"\r"}, want: "net/http", wantOK: true},
+		{name: "ctrl-j next", reads: []string{"\n\n\r"}, want: "net/http/httptest", wantOK: true},
+		{name: "ctrl-k previous", reads: []string{"\x0e\x0e\x0b\r"}, want: "net/url", wantOK: true},
+		{name: "arrow keys", reads: []string{"\x1b[B\x1b[B\x1b[A\r"}, want: "net/url", wantOK: true},
+		{name: "split arrow key", reads: []string{"\x1b", "[", "B", "\r"}, want: "net/url", wantOK: true},
+		{name: "esc", reads: []string{"\x1b"}},
+		{name: "ctrl-c", reads: []string{"\x03"}},
+		{name: "query", reads: []string{"url\r"}, want: "net/url", wantOK: true},
+		{name: "ctrl-w", reads: []string{"net/url/\x17httptest\r"}, want: "net/http/httptest", wantOK: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, w := io.Pipe()
+			defer w.Close()
+			go func() {
+				for _, s := range c.reads {
+					if _, err := w.Write([]byte(s)); err != nil {
+						return
+					}
+				}
+			}()
+
+			p := &picker{
+				ix:       ix,
+				fd:       -1,
+				in:       bufio.NewReader(r),
+				out:      bufio.NewWriter(ioutil.Discard),
+				synopses: make(map[string]string),
+			}
+
+			pkg, ok, err := p.run()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok != c.wantOK || pkg.ImportPath != c.want {
+				t.Errorf("got: %q, %t, want: %q, %t", pkg.ImportPath, ok, c.want, c.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/uudashr/gopkgs/v2"
+)
+
+func TestPrintTree(t *testing.T) {
+	pkgs := map[string]gopkgs.Pkg{
+		"/goroot/src/net":                     {ImportPath: "net", Standard: true, Root: "/goroot/src"},
+		"/goroot/src/net/http":                {ImportPath: "net/http", Standard: true, Root: "/goroot/src"},
+		"/goroot/src/fmt":                     {ImportPath: "fmt", Standard: true, Root: "/goroot/src"},
+		"/work/m":                             {ImportPath: "example.com/m", Module: "example.com/m", Root: "/work/m"},
+		"/work/m/internal/a":                  {ImportPath: "example.com/m/internal/a", Module: "example.com/m", Root: "/work/m"},
+		"/work/m/internal/b":                  {ImportPath: "example.com/m/internal/b", Module: "example.com/m", Root: "/work/m"},
+		"/gopath/src/github.com/user/project": {ImportPath: "github.com/user/project", Root: "/gopath/src"},
+	}
+
+	cases := []struct {
+		name  string
+		depth int
+		want  string
+	}{
+		{
+			name: "all levels",
+			want: `/gopath/src (1)
+  github.com (1)
+    user (1)
+      project (1)
+example.com/m (3)
+  internal (2)
+    a (1)
+    b (1)
+std (3)
+  fmt (1)
+  net (2)
+    http (1)
+`,
+		},
+		{
+			name:  "depth 1",
+			depth: 1,
+			want:  "/gopath/src (1)\nexample.com/m (3)\nstd (3)\n",
+		},
+		{
+			name:  "depth 2",
+			depth: 2,
+			want:  "/gopath/src (1)\n  github.com (1)\nexample.com/m (3)\n  internal (2)\nstd (3)\n  fmt (1)\n  net (2)\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printTree(&buf, buildTree(pkgs), c.depth); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildTreeCount(t *testing.T) {
+	root := buildTree(map[string]gopkgs.Pkg{
+		"/goroot/src/net":      {ImportPath: "net", Standard: true},
+		"/goroot/src/net/http": {ImportPath: "net/http", Standard: true},
+		"/work/m":              {ImportPath: "example.com/m", Module: "example.com/m"},
+	})
+
+	if root.count != 3 {
+		t.Errorf("got root count: %d, want: 3", root.count)
+	}
+
+	std := root.children["std"]
+	if std == nil || std.count != 2 || std.children["net"].count != 2 || std.children["net"].children["http"].count != 1 {
+		t.Errorf("got std node: %+v, want net (2) containing http (1)", std)
+	}
+
+	if m := root.children["example.com/m"]; m == nil || m.count != 1 || len(m.children) != 0 {
+		t.Errorf("got module node: %+v, want a leaf counting the module root package", m)
+	}
+}
@@ -1,17 +1,40 @@
 package gopkgs // import "github.com/uudashr/gopkgs/v2"
 
 import (
+	"time"
+
 	"github.com/uudashr/gopkgs/v2/internal"
 )
 
+// Listing modes.
+const (
+	ModeGOPATH = internal.ModeGOPATH
+	ModeModule = internal.ModeModule
+)
+
 // Pkg hold the information of the package.
 type Pkg internal.Pkg
 
 // Options for retrieve packages.
 type Options internal.Options
 
+// ListResult is the packages along with the details of the listing.
+type ListResult struct {
+	Pkgs            map[string]Pkg // packages, keyed by directory
+	Mode            string         // listing mode, ModeGOPATH or ModeModule
+	Roots           []string       // GOROOT and GOPATH src directories, or module directories scanned
+	DirsVisited     int            // number of directories visited
+	FilesRead       int            // number of .go files read to find the package name
+	ModListDuration time.Duration  // duration of "go list -m", zero if there is no workDir
+	WalkDuration    time.Duration  // duration of walking the roots
+	FilesDuration   time.Duration  // duration of reading the file listing and imports, if requested
+	Duration        time.Duration  // total duration
+}
+
 // List packages on workDir.
 // workDir is required for module mode. If the workDir is not under module, then it will fallback to GOPATH mode.
+// In module mode, the modules not downloaded are read from their zip on the local (file://) GOPROXY, if any.
+// The vendor directories and the nested modules are not walked as part of a module.
 func List(opts Options) (map[string]Pkg, error) {
 	result, err := internal.List(internal.Options(opts))
 	if err != nil {
@@ -24,3 +47,28 @@ func List(opts Options) (map[string]Pkg, error) {
 	}
 	return pkgs, nil
 }
+
+// ListWithResult list packages on workDir same as List, along with the details of the listing.
+func ListWithResult(opts Options) (*ListResult, error) {
+	result, err := internal.ListWithResult(internal.Options(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make(map[string]Pkg, len(result.Pkgs))
+	for key, pkg := range result.Pkgs {
+		pkgs[key] = Pkg(pkg)
+	}
+
+	return &ListResult{
+		Pkgs:            pkgs,
+		Mode:            result.Mode,
+		Roots:           result.Roots,
+		DirsVisited:     result.DirsVisited,
+		FilesRead:       result.FilesRead,
+		ModListDuration: result.ModListDuration,
+		WalkDuration:    result.WalkDuration,
+		FilesDuration:   result.FilesDuration,
+		Duration:        result.Duration,
+	}, nil
+}
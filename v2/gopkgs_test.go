@@ -1,6 +1,13 @@
 package gopkgs_test
 
 import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	gopkgs "github.com/uudashr/gopkgs/v2"
@@ -21,6 +28,33 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListWithResult(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip non-short mode")
+	}
+
+	result, err := gopkgs.ListWithResult(gopkgs.Options{})
+	if err != nil {
+		t.Fatal("fail getting packages:", err)
+	}
+
+	if got, want := result.Mode, gopkgs.ModeGOPATH; got != want {
+		t.Error("got:", got, "want:", want)
+	}
+
+	if got := len(result.Roots); got == 0 {
+		t.Error("got:", got, "want: greater than 0")
+	}
+
+	if got, want := result.DirsVisited, len(result.Pkgs); got < want {
+		t.Error("got:", got, "want: at least", want)
+	}
+
+	if got, want := result.FilesRead, len(result.Pkgs); got < want {
+		t.Error("got:", got, "want: at least", want)
+	}
+}
+
 func BenchmarkList(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if _, err := gopkgs.List(gopkgs.Options{}); err != nil {
@@ -28,3 +62,109 @@ func BenchmarkList(b *testing.B) {
 		}
 	}
 }
+
+// syntheticGopath creates GOROOT and GOPATH roots, each having pkgsPerRoot packages of
+// filesPerPkg files, then set them on the default build context until the returned
+// function called.
+func syntheticGopath(tb testing.TB, roots, pkgsPerRoot, filesPerPkg int) func() {
+	base, err := ioutil.TempDir("", "gopkgs")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	var gopath []string
+	for r := 0; r < roots; r++ {
+		rootDir := filepath.Join(base, fmt.Sprintf("root%d", r))
+		for p := 0; p < pkgsPerRoot; p++ {
+			pkgDir := filepath.Join(rootDir, "src", fmt.Sprintf("example.com/r%d/g%d/pkg%d", r, p%10, p))
+			if err = os.MkdirAll(pkgDir, 0755); err != nil {
+				tb.Fatal(err)
+			}
+
+			for f := 0; f < filesPerPkg; f++ {
+				content := fmt.Sprintf("// Package pkg%d is generated.\npackage pkg%d\n", p, p)
+				if err = ioutil.WriteFile(filepath.Join(pkgDir, fmt.Sprintf("file%d.go", f)), []byte(content), 0644); err != nil {
+					tb.Fatal(err)
+				}
+			}
+		}
+
+		if r > 0 {
+			gopath = append(gopath, rootDir)
+		}
+	}
+
+	goroot, origGOPATH := build.Default.GOROOT, build.Default.GOPATH
+	build.Default.GOROOT = filepath.Join(base, "root0")
+	build.Default.GOPATH = strings.Join(gopath, string(filepath.ListSeparator))
+	return func() {
+		build.Default.GOROOT, build.Default.GOPATH = goroot, origGOPATH
+		os.RemoveAll(base)
+	}
+}
+
+func TestListConcurrency(t *testing.T) {
+	cleanup := syntheticGopath(t, 3, 50, 3)
+	defer cleanup()
+
+	want, err := gopkgs.List(gopkgs.Options{Concurrency: 1})
+	if err != nil {
+		t.Fatal("fail getting packages:", err)
+	}
+
+	if got, want := len(want), 150; got != want {
+		t.Error("got:", got, "want:", want)
+	}
+
+	got, err := gopkgs.List(gopkgs.Options{Concurrency: 8})
+	if err != nil {
+		t.Fatal("fail getting packages:", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatal("got:", len(got), "want:", len(want))
+	}
+
+	for dir, pkg := range want {
+		if got[dir].ImportPath != pkg.ImportPath || got[dir].Name != pkg.Name {
+			t.Error("got:", got[dir], "want:", pkg)
+		}
+	}
+}
+
+func BenchmarkListConcurrency(b *testing.B) {
+	cleanup := syntheticGopath(b, 4, 500, 5)
+	defer cleanup()
+
+	seen := make(map[int]bool)
+	for _, concurrency := range []int{1, 2, 4, runtime.NumCPU()} {
+		if seen[concurrency] {
+			continue
+		}
+		seen[concurrency] = true
+
+		concurrency := concurrency
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := gopkgs.List(gopkgs.Options{Concurrency: concurrency}); err != nil {
+					b.Fatal("err:", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkListLargePackages lists 100 packages of 200 files each, the walk costing per
+// directory rather than per file.
+func BenchmarkListLargePackages(b *testing.B) {
+	cleanup := syntheticGopath(b, 1, 100, 200)
+	defer cleanup()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := gopkgs.List(gopkgs.Options{}); err != nil {
+			b.Fatal("err:", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPkgFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gopkgs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo.go":         "package foo\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n",
+		"cgo.go":         "package foo\n\nimport \"C\"\nimport \"unsafe\"\n",
+		"ignored.go":     "//go:build ignore\n\npackage foo\n",
+		"foo_test.go":    "package foo\n",
+		"export_test.go": "package foo_test\n",
+		"_hidden.go":     "package foo\n",
+		"README.md":      "# foo\n",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := newEnv(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := Pkg{Dir: dir, Name: "foo"}
+	if err = readPkgFiles(e, &pkg, true, true); err != nil {
+		t.Fatal("fail reading files:", err)
+	}
+
+	cases := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "GoFiles", got: pkg.GoFiles, want: []string{"foo.go"}},
+		{name: "CgoFiles", got: pkg.CgoFiles, want: []string{"cgo.go"}},
+		{name: "TestGoFiles", got: pkg.TestGoFiles, want: []string{"foo_test.go"}},
+		{name: "XTestGoFiles", got: pkg.XTestGoFiles, want: []string{"export_test.go"}},
+		{name: "XTestName", got: pkg.XTestName, want: "foo_test"},
+		{name: "IgnoredFiles", got: pkg.IgnoredFiles, want: []string{"ignored.go"}},
+		{name: "Imports", got: pkg.Imports, want: []string{"fmt", "os", "unsafe"}},
+	}
+
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Error("got:", c.got, "want:", c.want, "field:", c.name)
+		}
+	}
+}
+
+func TestReadPkgFilesGOOS(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"foo.go", "foo_windows.go", "foo_linux.go", "foo_arm64.go", "foo_amd64.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("package foo\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := newEnv(Options{GOOS: "windows", GOARCH: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := Pkg{Dir: dir, Name: "foo"}
+	if err = readPkgFiles(e, &pkg, true, false); err != nil {
+		t.Fatal("fail reading files:", err)
+	}
+
+	if want := []string{"foo.go", "foo_arm64.go", "foo_windows.go"}; !reflect.DeepEqual(pkg.GoFiles, want) {
+		t.Error("got GoFiles:", pkg.GoFiles, "want:", want)
+	}
+
+	if want := []string{"foo_amd64.go", "foo_linux.go"}; !reflect.DeepEqual(pkg.IgnoredFiles, want) {
+		t.Error("got IgnoredFiles:", pkg.IgnoredFiles, "want:", want)
+	}
+}
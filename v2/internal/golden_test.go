@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// fakeGoEnv makes the test binary act as the go command, see fakeGo.
+const fakeGoEnv = "GOPKGS_TEST_FAKE_GO"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeGoEnv) == "1" {
+		os.Exit(fakeGo(os.Args[1:]))
+	}
+
+	cleanup, err := installFakeGo()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fail installing fake go command:", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
+
+// installFakeGo puts a copy of the test binary named go in front of PATH.
+func installFakeGo() (func(), error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	binDir, err := ioutil.TempDir("", "gopkgs-fakego")
+	if err != nil {
+		return nil, err
+	}
+
+	name := "go"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	if err = copyFile(filepath.Join(binDir, name), exe); err != nil {
+		os.RemoveAll(binDir)
+		return nil, err
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", binDir+string(filepath.ListSeparator)+path)
+	os.Setenv(fakeGoEnv, "1")
+	return func() {
+		os.Setenv("PATH", path)
+		os.Unsetenv(fakeGoEnv)
+		os.RemoveAll(binDir)
+	}, nil
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fakeGo replies "go list -m -f={{.Path}};{{.Dir}} all" from the golist.txt found on
+// the working directory or its parents. Each line of golist.txt is the module path, its
+// directory relative to golist.txt and optionally its version, separated by ";". Outside of
+// a module it fails like go.
+// It replies "go env" from the environment, else from the key=value lines of $GOENV, the
+// file written by go env -w.
+func fakeGo(args []string) int {
+	if len(args) > 0 && args[0] == "env" {
+		written := make(map[string]string)
+		if b, err := ioutil.ReadFile(os.Getenv("GOENV")); err == nil {
+			for _, line := range strings.Split(string(b), "\n") {
+				if i := strings.IndexByte(line, '='); i > 0 {
+					written[line[:i]] = line[i+1:]
+				}
+			}
+		}
+
+		for _, key := range args[1:] {
+			if v := os.Getenv(key); v != "" {
+				fmt.Println(v)
+			} else {
+				fmt.Println(written[key])
+			}
+		}
+		return 0
+	}
+
+	if len(args) < 2 || args[0] != "list" || args[1] != "-m" {
+		fmt.Fprintln(os.Stderr, "fake go: unsupported command:", strings.Join(args, " "))
+		return 2
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fake go:", err)
+		return 1
+	}
+
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		f, err := os.Open(filepath.Join(dir, "golist.txt"))
+		if os.IsNotExist(err) {
+			if filepath.Dir(dir) == dir {
+				fmt.Fprintln(os.Stderr, "go: cannot match \"all\": go.mod file not found in current directory or any parent directory")
+				return 1
+			}
+			continue
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "fake go:", err)
+			return 1
+		}
+		defer f.Close()
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			ls := strings.Split(s.Text(), ";")
+			modDir := ""
+			if ls[1] != "" {
+				modDir = filepath.Join(dir, filepath.FromSlash(ls[1]))
+			}
+			version := ""
+			if len(ls) > 2 {
+				version = ls[2]
+			}
+			fmt.Printf("%s;%s;%s\n", ls[0], modDir, version)
+		}
+		return 0
+	}
+}
+
+// fixture returns the absolute path of the testdata fixture.
+func fixture(t *testing.T, name string) string {
+	dir, err := filepath.Abs(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestListGolden(t *testing.T) {
+	defer setenv(t, "GOMODCACHE", "")()
+
+	goroot := fixture(t, "goroot")
+	gopath := fixture(t, "gopath")
+
+	cases := []struct {
+		name    string
+		opts    Options
+		goproxy string
+	}{
+		{name: "gopath", opts: Options{}},
+		{name: "gopath-novendor", opts: Options{NoVendor: true}},
+		{name: "gopath-workdir", opts: Options{WorkDir: fixture(t, "gopath/src/example.com/app")}},
+		{name: "module", opts: Options{WorkDir: fixture(t, "mod")}},
+		{name: "module-modcache", opts: Options{WorkDir: fixture(t, "mod"), IncludeModCache: true}},
+		{name: "module-main", opts: Options{WorkDir: fixture(t, "mod"), IncludeMain: true}},
+		{name: "module-proxy", opts: Options{WorkDir: fixture(t, "mod"), Files: true}, goproxy: "file://" + filepath.ToSlash(fixture(t, "proxy"))},
+		{name: "module-subdir", opts: Options{WorkDir: fixture(t, "mod/internal/store")}},
+		{name: "workspace", opts: Options{WorkDir: fixture(t, "work")}},
+		{name: "files", opts: Options{WorkDir: fixture(t, "mod"), Files: true, Imports: true}},
+		{name: "gopath-files", opts: Options{Files: true, Imports: true}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			goproxy := c.goproxy
+			if goproxy == "" {
+				goproxy = "off"
+			}
+			defer setenv(t, "GOPROXY", goproxy)()
+
+			opts := c.opts
+			opts.GOROOT = goroot
+			opts.GOPATH = gopath
+
+			pkgs, err := List(opts)
+			if err != nil {
+				t.Fatal("fail getting packages:", err)
+			}
+
+			got := formatGolden(t, pkgs)
+			goldenFile := filepath.Join("testdata", "golden", c.name+".golden")
+			if *update {
+				if err = os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = ioutil.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatal("fail reading golden file, run with -update to create it:", err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// formatGolden formats the packages sorted by import path, the paths relative to testdata.
+func formatGolden(t *testing.T, pkgs map[string]Pkg) []byte {
+	testdata := fixture(t, "")
+	rel := func(path string) string {
+		if path == "" {
+			return ""
+		}
+
+		r, err := filepath.Rel(testdata, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return filepath.ToSlash(r)
+	}
+
+	list := make([]Pkg, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		pkg.Dir = rel(pkg.Dir)
+		pkg.Root = rel(pkg.Root)
+		list = append(list, pkg)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ImportPath != list[j].ImportPath {
+			return list[i].ImportPath < list[j].ImportPath
+		}
+		return list[i].Dir < list[j].Dir
+	})
+
+	var buf bytes.Buffer
+	for _, pkg := range list {
+		b, err := json.Marshal(pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// omit the zero values to keep the golden files readable
+		var fields map[string]interface{}
+		if err = json.Unmarshal(b, &fields); err != nil {
+			t.Fatal(err)
+		}
+
+		for key, value := range fields {
+			if value == nil || value == "" || value == false {
+				delete(fields, key)
+			}
+		}
+
+		if b, err = json.Marshal(fields); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	g := NewGraph(map[string]Pkg{
+		"/src/a": {ImportPath: "a", Imports: []string{"b", "fmt"}},
+		"/src/b": {ImportPath: "b", Imports: []string{"c"}},
+		"/src/c": {ImportPath: "c"},
+		"/src/d": {ImportPath: "d", Imports: []string{"c"}},
+	})
+
+	cases := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{name: "Imports(a)", got: g.Imports("a"), want: []string{"b"}},
+		{name: "Importers(c)", got: g.Importers("c"), want: []string{"b", "d"}},
+		{name: "Deps(a)", got: g.Deps("a"), want: []string{"b", "c"}},
+		{name: "ReverseDeps(c)", got: g.ReverseDeps("c"), want: []string{"a", "b", "d"}},
+		{name: "Deps(c)", got: g.Deps("c"), want: nil},
+	}
+
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Error("got:", c.got, "want:", c.want, "case:", c.name)
+		}
+	}
+}
+
+func TestGraphCycles(t *testing.T) {
+	g := NewGraph(map[string]Pkg{
+		"/src/a": {ImportPath: "a", Imports: []string{"b"}},
+		"/src/b": {ImportPath: "b", Imports: []string{"c"}},
+		"/src/c": {ImportPath: "c", Imports: []string{"a", "d"}},
+		"/src/d": {ImportPath: "d"},
+		"/src/e": {ImportPath: "e", Imports: []string{"e"}},
+		"/src/f": {ImportPath: "f", Imports: []string{"g"}},
+		"/src/g": {ImportPath: "g", Imports: []string{"f"}},
+	})
+
+	want := [][]string{
+		{"a", "b", "c", "a"},
+		{"e", "e"},
+		{"f", "g", "f"},
+	}
+	if got := g.Cycles(); !reflect.DeepEqual(got, want) {
+		t.Error("got:", got, "want:", want)
+	}
+}
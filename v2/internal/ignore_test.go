@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnored(t *testing.T) {
+	rules := parseIgnore("/repo", []byte(`# generated code
+bazel-out/
+/third_party/js
+gen*
+!generator
+docs/**/examples
+\#hash
+`))
+
+	cases := []struct {
+		dir     string
+		ignored bool
+	}{
+		{dir: "/repo/bazel-out", ignored: true},
+		{dir: "/repo/svc/bazel-out", ignored: true},
+		{dir: "/repo/third_party/js", ignored: true},
+		{dir: "/repo/svc/third_party/js", ignored: false},
+		{dir: "/repo/third_party/go", ignored: false},
+		{dir: "/repo/generated", ignored: true},
+		{dir: "/repo/svc/gen", ignored: true},
+		{dir: "/repo/generator", ignored: false},
+		{dir: "/repo/docs/examples", ignored: true},
+		{dir: "/repo/docs/a/b/examples", ignored: true},
+		{dir: "/repo/examples", ignored: false},
+		{dir: "/repo/#hash", ignored: true},
+		{dir: "/repo", ignored: false},
+		{dir: "/other/bazel-out", ignored: false},
+	}
+
+	for _, c := range cases {
+		if got := ignored(rules, "/", c.dir); got != c.ignored {
+			t.Errorf("ignored(%q) = %v, want: %v", c.dir, got, c.ignored)
+		}
+	}
+}
+
+func TestListIgnore(t *testing.T) {
+	fsys := fstest.MapFS{
+		"gopath/.gopkgsignore":                                 {Data: []byte("bazel-out\n")},
+		"gopath/src/example.com/repo/.gopkgsignore":            {Data: []byte("/third_party/js\n")},
+		"gopath/src/example.com/repo/.gitignore":               {Data: []byte("dist\n")},
+		"gopath/src/example.com/repo/repo.go":                  {Data: []byte("package repo\n")},
+		"gopath/src/example.com/repo/third_party/js/js.go":     {Data: []byte("package js\n")},
+		"gopath/src/example.com/repo/third_party/go/go.go":     {Data: []byte("package gogo\n")},
+		"gopath/src/example.com/repo/dist/dist.go":             {Data: []byte("package dist\n")},
+		"gopath/src/example.com/repo/bazel-out/out.go":         {Data: []byte("package out\n")},
+		"gopath/src/example.com/repo/svc/.gopkgsignore":        {Data: []byte("*\n!api\n")},
+		"gopath/src/example.com/repo/svc/api/api.go":           {Data: []byte("package api\n")},
+		"gopath/src/example.com/repo/svc/internal/internal.go": {Data: []byte("package internal\n")},
+		"gopath/src/example.com/other/bazel-out/out.go":        {Data: []byte("package out\n")},
+	}
+
+	cases := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "gopkgsignore",
+			want: []string{
+				"example.com/other/bazel-out",
+				"example.com/repo",
+				"example.com/repo/bazel-out",
+				"example.com/repo/dist",
+				"example.com/repo/svc/api",
+				"example.com/repo/third_party/go",
+			},
+		},
+		{
+			name: "exclude",
+			opts: Options{Exclude: []string{"bazel-out/"}},
+			want: []string{
+				"example.com/repo",
+				"example.com/repo/dist",
+				"example.com/repo/svc/api",
+				"example.com/repo/third_party/go",
+			},
+		},
+		{
+			name: "workdir",
+			opts: Options{WorkDir: "/gopath"},
+			want: []string{
+				"example.com/repo",
+				"example.com/repo/dist",
+				"example.com/repo/svc/api",
+				"example.com/repo/third_party/go",
+			},
+		},
+		{
+			name: "gitignore",
+			opts: Options{GitIgnore: true},
+			want: []string{
+				"example.com/other/bazel-out",
+				"example.com/repo",
+				"example.com/repo/bazel-out",
+				"example.com/repo/svc/api",
+				"example.com/repo/third_party/go",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := c.opts
+			opts.FS = fsys
+			opts.GOROOT = "/goroot"
+			opts.GOPATH = "/gopath"
+
+			pkgs, err := List(opts)
+			if err != nil {
+				t.Fatal("fail getting packages:", err)
+			}
+
+			var got []string
+			for _, pkg := range pkgs {
+				got = append(got, pkg.ImportPath)
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got: %v, want: %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/mod/semver"
+)
+
+// Module is a module version, either on the build list or downloaded in the module cache.
+type Module struct {
+	Path    string // module path
+	Version string // module version, empty for the main modules
+	Dir     string // directory holding the module sources, empty if not downloaded
+
+	// Only for the modules of a local GOPROXY
+	Time  time.Time // version time, from the .info file
+	GoMod string    // path of the .mod file
+	Zip   string    // path of the module zip
+}
+
+// ListModules returns the modules on the build list of workDir, as reported by "go list -m all".
+func ListModules(opts Options) ([]Module, error) {
+	if opts.WorkDir == "" {
+		return nil, errors.New("workDir is required to list the modules")
+	}
+
+	mods, err := listMods(opts.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Module, len(mods))
+	for i, m := range mods {
+		out[i] = Module{Path: m.path, Version: m.version, Dir: m.dir}
+	}
+	return out, nil
+}
+
+// ListModCache returns the module versions downloaded in the module cache, sorted by path
+// then version. The module cache is $GOMODCACHE, or pkg/mod of the first GOPATH entry.
+func ListModCache(opts Options) ([]Module, error) {
+	e, err := newEnv(opts)
+	if err != nil {
+		return nil, err
+	}
+	return listModCache(e)
+}
+
+// ListModule returns the packages of the module, the module directory being walked
+// the same as the modules on the build list. The module zip is read instead if there is
+// no module directory, the package directories being named under the zip file path.
+func ListModule(opts Options, m Module) (map[string]Pkg, error) {
+	e, err := newEnv(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	md := mod{path: m.Path, version: m.Version, dir: m.Dir}
+	if md.dir == "" && m.Zip != "" {
+		zfs, err := openModZip(e, m)
+		if err != nil {
+			return nil, err
+		}
+
+		zopts := opts
+		zopts.FS, zopts.Overlay = zfs, nil
+		if e, err = newEnv(zopts); err != nil {
+			return nil, err
+		}
+		md.dir = m.Zip
+	}
+
+	if md.dir == "" {
+		return nil, fmt.Errorf("module %s@%s not downloaded", m.Path, m.Version)
+	}
+
+	result := &ListResult{Pkgs: make(map[string]Pkg)}
+	roots := []root{{
+		dir: md.dir,
+		collect: func(sem chan struct{}, st *stats) (map[string]Pkg, error) {
+			return collectModPkgs(e, md, sem, st)
+		},
+	}}
+	if err := listPkgs(e, opts, roots, result); err != nil {
+		return nil, err
+	}
+	return result.Pkgs, nil
+}
+
+// modCacheDir returns the module cache directory.
+func modCacheDir(e *env) string {
+	if dir := goEnv(e, "GOMODCACHE"); dir != "" {
+		return dir
+	}
+
+	list := filepath.SplitList(e.ctxt.GOPATH)
+	if len(list) == 0 || list[0] == "" {
+		return ""
+	}
+	return filepath.Join(list[0], "pkg", "mod")
+}
+
+func listModCache(e *env) ([]Module, error) {
+	dir := modCacheDir(e)
+	if dir == "" {
+		return nil, errors.New("module cache not found, neither GOMODCACHE nor GOPATH is set")
+	}
+
+	var mods []Module
+	if err := scanModCache(e, dir, "", &mods); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mods, func(i, j int) bool {
+		if mods[i].Path != mods[j].Path {
+			return mods[i].Path < mods[j].Path
+		}
+		return semver.Compare(mods[i].Version, mods[j].Version) < 0
+	})
+	return mods, nil
+}
+
+// scanModCache looks for the module version directories, named path@version, under dir.
+// The prefix is the escaped module path of dir.
+func scanModCache(e *env, dir, prefix string, mods *[]Module) error {
+	des, err := e.readDir(dir)
+	if err != nil {
+		if prefix == "" {
+			return err
+		}
+		return nil
+	}
+
+	for _, de := range des {
+		name := de.Name()
+		if !de.IsDir() || (prefix == "" && name == "cache") {
+			// cache/download holds the zips, not the sources.
+			continue
+		}
+
+		elem, escVersion := name, ""
+		if i := strings.IndexByte(name, '@'); i >= 0 {
+			elem, escVersion = name[:i], name[i+1:]
+		}
+
+		escPath := elem
+		if prefix != "" {
+			escPath = prefix + "/" + elem
+		}
+
+		if escVersion == "" {
+			if err := scanModCache(e, filepath.Join(dir, name), escPath, mods); err != nil {
+				return err
+			}
+			continue
+		}
+
+		modPath, err := unescapeModPath(escPath)
+		if err != nil {
+			continue
+		}
+
+		version, err := unescapeModPath(escVersion)
+		if err != nil {
+			continue
+		}
+
+		*mods = append(*mods, Module{Path: modPath, Version: version, Dir: filepath.Join(dir, name)})
+	}
+	return nil
+}
+
+// unescapeModPath decodes the module path or version of the module cache, where each upper
+// case letter is escaped as "!" followed by the lower case letter.
+func unescapeModPath(escaped string) (string, error) {
+	var sb strings.Builder
+	bang := false
+	for _, r := range escaped {
+		if r >= utf8.RuneSelf {
+			return "", fmt.Errorf("invalid escaped module path %q", escaped)
+		}
+
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", fmt.Errorf("invalid escaped module path %q", escaped)
+			}
+			sb.WriteRune(r - 'a' + 'A')
+			bang = false
+		case r == '!':
+			bang = true
+		case r >= 'A' && r <= 'Z':
+			return "", fmt.Errorf("invalid escaped module path %q", escaped)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	if bang {
+		return "", fmt.Errorf("invalid escaped module path %q", escaped)
+	}
+	return sb.String(), nil
+}
+
+// notRequiredMods returns the latest version of each module in the module cache which is
+// not on the build list. The latest is the highest release, or the highest pre-release and
+// pseudo-version if there is no release, same as the "latest" query of the go command.
+func notRequiredMods(e *env, required []mod) []mod {
+	cached, err := listModCache(e)
+	if err != nil {
+		// no module cache, nothing to add
+		return nil
+	}
+
+	skip := make(map[string]bool, len(required))
+	for _, m := range required {
+		skip[m.path] = true
+	}
+
+	var (
+		out    []mod
+		latest = make(map[string]int) // index on out by module path
+	)
+	for _, m := range cached {
+		if skip[m.Path] {
+			continue
+		}
+
+		i, found := latest[m.Path]
+		if !found {
+			latest[m.Path] = len(out)
+			out = append(out, mod{path: m.Path, version: m.Version, dir: m.Dir, notRequired: true})
+			continue
+		}
+
+		if newerVersion(m.Version, out[i].version) {
+			out[i].version, out[i].dir = m.Version, m.Dir
+		}
+	}
+	return out
+}
+
+// newerVersion reports whether v is preferred over w as the latest version, the releases
+// being preferred over the pre-releases.
+func newerVersion(v, w string) bool {
+	vRelease := semver.IsValid(v) && semver.Prerelease(v) == ""
+	wRelease := semver.IsValid(w) && semver.Prerelease(w) == ""
+	if vRelease != wRelease {
+		return vRelease
+	}
+	return semver.Compare(v, w) > 0
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"go/build"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func TestListModCache(t *testing.T) {
+	defer setenv(t, "GOMODCACHE", "")()
+
+	fsys := fstest.MapFS{
+		"gopath/pkg/mod/cache/download/example.com/foo/@v/v1.0.0.zip":                   {Data: []byte("zip")},
+		"gopath/pkg/mod/example.com/foo@v1.10.0/foo.go":                                 {Data: []byte("package foo\n")},
+		"gopath/pkg/mod/example.com/foo@v1.2.0/foo.go":                                  {Data: []byte("package foo\n")},
+		"gopath/pkg/mod/example.com/foo@v1.2.0/sub/sub.go":                              {Data: []byte("package sub\n")},
+		"gopath/pkg/mod/example.com/foo@v1.2.0/nested/go.mod":                           {Data: []byte("module example.com/foo/nested\n")},
+		"gopath/pkg/mod/example.com/foo@v1.2.0/nested/nested.go":                        {Data: []byte("package nested\n")},
+		"gopath/pkg/mod/example.com/foo/nested@v0.1.0/nested.go":                        {Data: []byte("package nested\n")},
+		"gopath/pkg/mod/github.com/!azure/go-autorest@v14.2.0+incompatible/autorest.go": {Data: []byte("package autorest\n")},
+		"gopath/pkg/mod/github.com/!bad!/x@v1.0.0/x.go":                                 {Data: []byte("package x\n")},
+	}
+
+	opts := Options{FS: fsys, GOPATH: "/gopath"}
+	mods, err := ListModCache(opts)
+	if err != nil {
+		t.Fatal("fail listing module cache:", err)
+	}
+
+	want := []Module{
+		{Path: "example.com/foo", Version: "v1.2.0", Dir: "/gopath/pkg/mod/example.com/foo@v1.2.0"},
+		{Path: "example.com/foo", Version: "v1.10.0", Dir: "/gopath/pkg/mod/example.com/foo@v1.10.0"},
+		{Path: "example.com/foo/nested", Version: "v0.1.0", Dir: "/gopath/pkg/mod/example.com/foo/nested@v0.1.0"},
+		{Path: "github.com/Azure/go-autorest", Version: "v14.2.0+incompatible", Dir: "/gopath/pkg/mod/github.com/!azure/go-autorest@v14.2.0+incompatible"},
+	}
+	if !reflect.DeepEqual(mods, want) {
+		t.Errorf("got: %v, want: %v", mods, want)
+	}
+
+	pkgs, err := ListModule(opts, mods[0])
+	if err != nil {
+		t.Fatal("fail listing module:", err)
+	}
+
+	var importPaths []string
+	for _, pkg := range pkgs {
+		importPaths = append(importPaths, pkg.ImportPath)
+	}
+	sort.Strings(importPaths)
+
+	if want := []string{"example.com/foo", "example.com/foo/sub"}; !reflect.DeepEqual(importPaths, want) {
+		t.Errorf("got: %v, want: %v", importPaths, want)
+	}
+}
+
+func TestUnescapeModPath(t *testing.T) {
+	cases := []struct {
+		escaped string
+		path    string
+		valid   bool
+	}{
+		{escaped: "github.com/!azure/go-autorest", path: "github.com/Azure/go-autorest", valid: true},
+		{escaped: "github.com/!burnt!sushi/toml", path: "github.com/BurntSushi/toml", valid: true},
+		{escaped: "v1.0.0-!r!c1", path: "v1.0.0-RC1", valid: true},
+		{escaped: "github.com/Azure/go-autorest"},
+		{escaped: "github.com/!1"},
+		{escaped: "github.com/x!"},
+	}
+
+	for _, c := range cases {
+		path, err := unescapeModPath(c.escaped)
+		if valid := err == nil; valid != c.valid || path != c.path {
+			t.Errorf("unescapeModPath(%q) = %q, %v, want: %q, valid: %v", c.escaped, path, err, c.path, c.valid)
+		}
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	cases := []struct {
+		v, w  string
+		newer bool
+	}{
+		{v: "v1.10.0", w: "v1.2.0", newer: true},
+		{v: "v1.2.0", w: "v1.10.0", newer: false},
+		{v: "v1.0.0", w: "v1.1.0-rc.1", newer: true},
+		{v: "v1.1.0-rc.2", w: "v1.1.0-rc.1", newer: true},
+		{v: "v2.0.0+incompatible", w: "v1.9.0", newer: true},
+		{v: "bad", w: "v0.0.0-20210101000000-abcdef123456", newer: false},
+	}
+
+	for _, c := range cases {
+		if got := newerVersion(c.v, c.w); got != c.newer {
+			t.Errorf("newerVersion(%q, %q) = %v, want: %v", c.v, c.w, got, c.newer)
+		}
+	}
+}
+
+func TestModCacheDir(t *testing.T) {
+	defer setenv(t, "GOMODCACHE", "")()
+	defer setenv(t, "GOPATH", "")()
+
+	e := &env{ctxt: build.Context{GOPATH: "/gopath"}}
+	if got, want := goEnv(e, "GOPATH"), "/gopath"; got != want {
+		t.Errorf("go env GOPATH got: %q, want: %q", got, want)
+	}
+
+	if got, want := modCacheDir(e), "/gopath/pkg/mod"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	os.Setenv("GOMODCACHE", "/modcache")
+	if got, want := modCacheDir(e), "/modcache"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+// setenv sets the environment variable, returning the function restoring it.
+func setenv(t *testing.T, key, value string) func() {
+	old, found := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if found {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
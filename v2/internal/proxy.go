@@ -0,0 +1,284 @@
+package internal // import "github.com/uudashr/gopkgs/v2/internal"
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// ListProxyModules returns the module versions in the local GOPROXY directory, the first
+// file:// entry of GOPROXY as set on the environment or by go env -w, sorted by path then
+// version. The packages of the module versions are listed by ListModule from the module
+// zip, without extracting it.
+func ListProxyModules(opts Options) ([]Module, error) {
+	e, err := newEnv(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := proxyDir(goEnv(e, "GOPROXY"))
+	if err != nil {
+		return nil, err
+	}
+
+	var mods []Module
+	if err := scanProxy(e, dir, "", &mods); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mods, func(i, j int) bool {
+		if mods[i].Path != mods[j].Path {
+			return mods[i].Path < mods[j].Path
+		}
+		return semver.Compare(mods[i].Version, mods[j].Version) < 0
+	})
+	return mods, nil
+}
+
+// proxyDir returns the directory of the first file:// entry of the GOPROXY list.
+func proxyDir(goproxy string) (string, error) {
+	for _, entry := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		if !strings.HasPrefix(entry, "file://") {
+			continue
+		}
+
+		u, err := url.Parse(entry)
+		if err != nil {
+			return "", err
+		}
+
+		dir := u.Path
+		if len(dir) >= 3 && dir[0] == '/' && dir[2] == ':' {
+			// file:///C:/proxy on Windows
+			dir = dir[1:]
+		}
+		return filepath.FromSlash(dir), nil
+	}
+	return "", errors.New("no local GOPROXY, expect a file:// entry on GOPROXY")
+}
+
+// scanProxy looks for the @v directories under dir, holding the versions of the module.
+// The prefix is the escaped module path of dir.
+func scanProxy(e *env, dir, prefix string, mods *[]Module) error {
+	des, err := e.readDir(dir)
+	if err != nil {
+		if prefix == "" {
+			return err
+		}
+		return nil
+	}
+
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+
+		name := de.Name()
+		if name == "@v" {
+			modPath, err := unescapeModPath(prefix)
+			if err != nil {
+				continue
+			}
+
+			*mods = append(*mods, proxyVersions(e, modPath, filepath.Join(dir, name))...)
+			continue
+		}
+
+		escPath := name
+		if prefix != "" {
+			escPath = prefix + "/" + name
+		}
+
+		if err := scanProxy(e, filepath.Join(dir, name), escPath, mods); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxyVersions returns the versions of the module in the @v directory, as listed on the
+// list file, or by the .info files if there is no list file.
+func proxyVersions(e *env, modPath, dir string) []Module {
+	var versions []string
+	if data, err := e.readFile(filepath.Join(dir, "list")); err == nil {
+		s := bufio.NewScanner(bytes.NewReader(data))
+		for s.Scan() {
+			if fields := strings.Fields(s.Text()); len(fields) > 0 {
+				versions = append(versions, fields[0])
+			}
+		}
+	} else {
+		des, err := e.readDir(dir)
+		if err != nil {
+			return nil
+		}
+
+		for _, de := range des {
+			if escVersion := strings.TrimSuffix(de.Name(), ".info"); escVersion != de.Name() {
+				if version, err := unescapeModPath(escVersion); err == nil {
+					versions = append(versions, version)
+				}
+			}
+		}
+	}
+
+	var mods []Module
+	for _, version := range versions {
+		base := filepath.Join(dir, escapeModPath(version))
+		m := Module{Path: modPath, Version: version}
+
+		var info struct {
+			Time time.Time
+		}
+		if data, err := e.readFile(base + ".info"); err == nil && json.Unmarshal(data, &info) == nil {
+			m.Time = info.Time
+		}
+
+		if _, err := fs.Stat(e.fsys, fsName(base+".mod")); err == nil {
+			m.GoMod = base + ".mod"
+		}
+
+		if _, err := fs.Stat(e.fsys, fsName(base+".zip")); err == nil {
+			m.Zip = base + ".zip"
+		}
+		mods = append(mods, m)
+	}
+	return mods
+}
+
+// escapeModPath encodes the module path or version of the proxy, where each upper case
+// letter is escaped as "!" followed by the lower case letter.
+func escapeModPath(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			sb.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// openModZip opens the module zip, returning the file system holding the module files
+// at the zip file path. E.g. the file sub/x.go of proxy/foo/@v/v1.0.0.zip is named
+// proxy/foo/@v/v1.0.0.zip/sub/x.go, the same way the module directories are on the host.
+func openModZip(e *env, m Module) (*zipFS, error) {
+	data, err := e.readFile(m.Zip)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &zipFS{
+		name: fsName(m.Zip),
+		root: m.Path + "@" + m.Version,
+		zr:   zr,
+	}, nil
+}
+
+// zipFS is the module zip mounted at the file name of the zip.
+type zipFS struct {
+	name string // file name of the zip
+	root string // directory holding the module files in the zip, path@version
+	zr   *zip.Reader
+}
+
+func (z *zipFS) zipName(op, name string) (string, error) {
+	if name == z.name {
+		return z.root, nil
+	}
+
+	if strings.HasPrefix(name, z.name+"/") {
+		return path.Join(z.root, name[len(z.name)+1:]), nil
+	}
+	return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (z *zipFS) Open(name string) (fs.File, error) {
+	zname, err := z.zipName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return z.zr.Open(zname)
+}
+
+// mountProxyZips mounts the zips of the local GOPROXY for the modules not downloaded, their
+// directory being set to the zip file name, so that their packages are listed from the zip.
+func mountProxyZips(e *env, mods []mod) {
+	proxy := ""
+	var zips []*zipFS
+	for i, m := range mods {
+		if m.dir != "" || m.version == "" {
+			continue
+		}
+
+		if proxy == "" {
+			dir, err := proxyDir(goEnv(e, "GOPROXY"))
+			if err != nil {
+				return
+			}
+			proxy = dir
+		}
+
+		zipFile := filepath.Join(proxy, filepath.FromSlash(escapeModPath(m.path)), "@v", escapeModPath(m.version)+".zip")
+		z, err := openModZip(e, Module{Path: m.path, Version: m.version, Zip: zipFile})
+		if err != nil {
+			continue
+		}
+
+		zips = append(zips, z)
+		mods[i].dir = zipFile
+	}
+
+	if len(zips) > 0 {
+		e.fsys = &mountFS{FS: e.fsys, zips: zips}
+	}
+}
+
+// mountFS is the file system with the module zips mounted at their file names.
+type mountFS struct {
+	fs.FS
+	zips []*zipFS
+}
+
+// target returns the file system of the name, the zip it is under if any.
+func (m *mountFS) target(name string) fs.FS {
+	for _, z := range m.zips {
+		if name == z.name || strings.HasPrefix(name, z.name+"/") {
+			return z
+		}
+	}
+	return m.FS
+}
+
+func (m *mountFS) Open(name string) (fs.File, error) {
+	return m.target(name).Open(name)
+}
+
+func (m *mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(m.target(name), name)
+}
+
+func (m *mountFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(m.target(name), name)
+}
+
+func (m *mountFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(m.target(name), name)
+}
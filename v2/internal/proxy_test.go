@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestListProxyModules(t *testing.T) {
+	defer setenv(t, "GOPROXY", "https://proxy.golang.org,file:///proxy|direct")()
+
+	fsys := fstest.MapFS{
+		"proxy/example.com/!foo/@v/list":        {Data: []byte("v1.0.0\nv1.1.0\n")},
+		"proxy/example.com/!foo/@v/v1.0.0.info": {Data: []byte(`{"Version":"v1.0.0","Time":"2021-01-02T03:04:05Z"}`)},
+		"proxy/example.com/!foo/@v/v1.0.0.mod":  {Data: []byte("module example.com/Foo\n")},
+		"proxy/example.com/!foo/@v/v1.0.0.zip": {Data: modZip(t, "example.com/Foo@v1.0.0", map[string]string{
+			"go.mod":           "module example.com/Foo\n",
+			"foo.go":           "package foo\n",
+			"foo_test.go":      "package foo\n",
+			"sub/sub.go":       "package sub\n",
+			"cmd/tool/main.go": "package main\n",
+		})},
+		"proxy/example.com/!foo/@v/v1.1.0.info":      {Data: []byte(`{"Version":"v1.1.0"}`)},
+		"proxy/example.com/bar/@v/v0.1.0-!r!c1.info": {Data: []byte(`{"Version":"v0.1.0-RC1"}`)},
+	}
+
+	opts := Options{FS: fsys}
+	mods, err := ListProxyModules(opts)
+	if err != nil {
+		t.Fatal("fail listing proxy modules:", err)
+	}
+
+	want := []Module{
+		{Path: "example.com/Foo", Version: "v1.0.0", Time: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), GoMod: "/proxy/example.com/!foo/@v/v1.0.0.mod", Zip: "/proxy/example.com/!foo/@v/v1.0.0.zip"},
+		{Path: "example.com/Foo", Version: "v1.1.0"},
+		{Path: "example.com/bar", Version: "v0.1.0-RC1"},
+	}
+	if !reflect.DeepEqual(mods, want) {
+		t.Fatalf("got: %v, want: %v", mods, want)
+	}
+
+	opts.Files = true
+	pkgs, err := ListModule(opts, mods[0])
+	if err != nil {
+		t.Fatal("fail listing module:", err)
+	}
+
+	wantPkgs := map[string]Pkg{
+		"/proxy/example.com/!foo/@v/v1.0.0.zip": {
+			Dir:         "/proxy/example.com/!foo/@v/v1.0.0.zip",
+			ImportPath:  "example.com/Foo",
+			Name:        "foo",
+			Root:        "/proxy/example.com/!foo/@v/v1.0.0.zip",
+			Module:      "example.com/Foo",
+			Version:     "v1.0.0",
+			GoFiles:     []string{"foo.go"},
+			TestGoFiles: []string{"foo_test.go"},
+		},
+		"/proxy/example.com/!foo/@v/v1.0.0.zip/sub": {
+			Dir:        "/proxy/example.com/!foo/@v/v1.0.0.zip/sub",
+			ImportPath: "example.com/Foo/sub",
+			Name:       "sub",
+			Root:       "/proxy/example.com/!foo/@v/v1.0.0.zip",
+			Module:     "example.com/Foo",
+			Version:    "v1.0.0",
+			GoFiles:    []string{"sub.go"},
+		},
+	}
+	if !reflect.DeepEqual(pkgs, wantPkgs) {
+		t.Errorf("got: %v, want: %v", pkgs, wantPkgs)
+	}
+
+	if _, err = ListModule(opts, mods[1]); err == nil {
+		t.Error("expect error listing the module without zip")
+	}
+}
+
+func TestListProxyModulesGoEnv(t *testing.T) {
+	goenv := filepath.Join(t.TempDir(), "env")
+	if err := ioutil.WriteFile(goenv, []byte("GOPROXY=file:///proxy\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer setenv(t, "GOENV", goenv)()
+	defer setenv(t, "GOPROXY", "")()
+
+	fsys := fstest.MapFS{
+		"proxy/example.com/foo/@v/v1.0.0.info": {Data: []byte(`{"Version":"v1.0.0"}`)},
+	}
+
+	mods, err := ListProxyModules(Options{FS: fsys})
+	if err != nil {
+		t.Fatal("fail listing proxy modules:", err)
+	}
+
+	if want := []Module{{Path: "example.com/foo", Version: "v1.0.0"}}; !reflect.DeepEqual(mods, want) {
+		t.Errorf("got: %v, want: %v", mods, want)
+	}
+}
+
+func TestProxyDir(t *testing.T) {
+	if _, err := proxyDir("https://proxy.golang.org,direct"); err == nil {
+		t.Error("expect error without file:// entry")
+	}
+}
+
+// modZip creates the module zip holding the files under root.
+func modZip(t *testing.T, root string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(root + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,4 @@
+/*
+Package util is a helper.
+*/
+package util
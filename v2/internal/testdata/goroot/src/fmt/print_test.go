@@ -0,0 +1 @@
+package fmt_test
@@ -0,0 +1,7 @@
+module example.com/mod
+
+go 1.16
+
+require example.com/replaced v1.0.0
+
+replace example.com/replaced => ./replaced
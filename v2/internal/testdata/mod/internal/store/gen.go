@@ -0,0 +1,7 @@
+//go:build ignore
+// +build ignore
+
+// Generates the store.
+package main
+
+func main() {}
@@ -0,0 +1,3 @@
+package mod
+
+import "example.com/replaced"
@@ -0,0 +1,12 @@
+package gopkgs // import "github.com/uudashr/gopkgs/v2"
+
+import (
+	"github.com/uudashr/gopkgs/v2/internal"
+)
+
+// MatchPattern returns a function matching import paths against pattern.
+// The pattern is an import path which may contain "..." wildcards, matching any string,
+// see: https://golang.org/cmd/go/#hdr-Package_lists_and_patterns
+func MatchPattern(pattern string) func(importPath string) bool {
+	return internal.MatchPattern(pattern)
+}